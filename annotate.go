@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+var (
+	annotationFlags []string
+
+	// parsed from --annotation; empty means tags are written with crane.Tag/Copy
+	annotations map[string]string
+)
+
+// Parse repeated --annotation key=value flags.
+func parseAnnotations() error {
+	if len(annotationFlags) == 0 {
+		return nil
+	}
+	annotations = make(map[string]string, len(annotationFlags))
+	for _, kv := range annotationFlags {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("Invalid annotation '%s': must be key=value", kv)
+		}
+		annotations[key] = value
+	}
+	return nil
+}
+
+// Fetch the source manifest (image or index) and annotate it.
+func annotateSource(ctx context.Context, ref name.Reference) (remote.Taggable, v1.Hash, error) {
+	desc, err := registryClient.Get(ctx, ref)
+	if err != nil {
+		return nil, v1.Hash{}, err
+	}
+
+	if desc.MediaType.IsIndex() {
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return nil, v1.Hash{}, err
+		}
+		return annotate(idx)
+	}
+	img, err := desc.Image()
+	if err != nil {
+		return nil, v1.Hash{}, err
+	}
+	return annotate(img)
+}
+
+// Add the annotations to the top-level manifest of an image or index. Layers
+// and child manifests are untouched; only the top-level digest changes.
+func annotate(t remote.Taggable) (remote.Taggable, v1.Hash, error) {
+	if idx, ok := t.(v1.ImageIndex); ok {
+		annotated := mutate.Annotations(idx, annotations).(v1.ImageIndex)
+		digest, err := annotated.Digest()
+		return annotated, digest, err
+	}
+	annotated := mutate.Annotations(t.(v1.Image), annotations).(v1.Image)
+	digest, err := annotated.Digest()
+	return annotated, digest, err
+}
+
+// For --dry-run with --annotation, the annotation and label changes the write
+// would make, as "Annotation: - k=v" / "Label: + k=v" lines reading
+// destination -> source. Without an existing destination everything is new.
+func metadataDiff(ctx context.Context, src sourceImage, destRef name.Reference, exists bool) ([]string, error) {
+	after, err := manifestAnnotations(src.artifact)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read source annotations: %v", err)
+	}
+	var before, beforeLabels map[string]string
+	if exists {
+		desc, err := registryClient.Get(ctx, destRef)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read destination manifest: %v", err)
+		}
+		var manifest struct {
+			Annotations map[string]string `json:"annotations"`
+		}
+		if err := json.Unmarshal(desc.Manifest, &manifest); err != nil {
+			return nil, fmt.Errorf("Failed to parse destination manifest: %v", err)
+		}
+		before = manifest.Annotations
+		details, _, err := fetchImage(ctx, destRef)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read destination labels: %v", err)
+		}
+		beforeLabels = details.labels
+	}
+	lines := diffSets("Annotation", formatLabels(before), formatLabels(after))
+	return append(lines, diffSets("Label", formatLabels(beforeLabels), formatLabels(src.labels))...), nil
+}
+
+// the top-level annotations of an image or index
+func manifestAnnotations(t remote.Taggable) (map[string]string, error) {
+	if idx, ok := t.(v1.ImageIndex); ok {
+		manifest, err := idx.IndexManifest()
+		if err != nil {
+			return nil, err
+		}
+		return manifest.Annotations, nil
+	}
+	manifest, err := t.(v1.Image).Manifest()
+	if err != nil {
+		return nil, err
+	}
+	return manifest.Annotations, nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import v1 "github.com/google/go-containerregistry/pkg/v1"
+
+// Annotations BuildKit puts on the attestation manifests (SBOM, provenance)
+// it adds to an index: the reference type, and the digest of the platform
+// image they describe.
+const (
+	annotationReferenceType   = "vnd.docker.reference.type"
+	annotationReferenceDigest = "vnd.docker.reference.digest"
+	referenceTypeAttestation  = "attestation-manifest"
+)
+
+// appended to the platform suffix for an attestation's tags, e.g. prod-linux-amd64-attestation
+const attestationSuffix = "-attestation"
+
+// --include-attestations: with --platform all, also tag each attestation
+// manifest, after the platform image it describes
+var includeAttestations bool
+
+// whether an index child is an attestation manifest rather than a platform image
+func isAttestation(desc v1.Descriptor) bool {
+	return desc.Annotations[annotationReferenceType] == referenceTypeAttestation
+}
+
+// The platform of the image an attestation manifest describes, if that
+// image is in the same index.
+func attestedPlatform(manifest *v1.IndexManifest, att v1.Descriptor) *v1.Platform {
+	subject := att.Annotations[annotationReferenceDigest]
+	for _, child := range manifest.Manifests {
+		if child.Digest.String() == subject && !isAttestation(child) {
+			return child.Platform
+		}
+	}
+	return nil
+}
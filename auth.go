@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/cli/cli/config"
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+var (
+	username      string
+	password      string
+	passwordStdin bool
+	registryToken string
+	dockerConfig  string
+	// --credential-helper: name of a docker-credential-<name> binary to ask first
+	credentialHelper string
+
+	// explicit credentials from the flags; nil means use registryKeychain
+	registryAuth authn.Authenticator
+	// keychain used when no explicit credentials are given
+	registryKeychain authn.Keychain = authn.DefaultKeychain
+)
+
+// Build the authenticator from --registry-token or --username/--password(-stdin),
+// if given.
+func configureAuth() error {
+	// An explicit --docker-config must hold a config.json. It is handed to
+	// the default keychain through DOCKER_CONFIG, which that already reads;
+	// a DOCKER_CONFIG without one means anonymous access, as for docker.
+	if dockerConfig != "" {
+		if _, err := os.Stat(filepath.Join(dockerConfig, config.ConfigFileName)); err != nil {
+			return fmt.Errorf("Docker config not found in '%s': %v", dockerConfig, err)
+		}
+		if err := os.Setenv("DOCKER_CONFIG", dockerConfig); err != nil {
+			return err
+		}
+	}
+
+	// Docker config credentials win; the cloud helpers fill the gaps.
+	switch keychainMode {
+	case keychainDocker:
+	case keychainCloud:
+		registryKeychain = authn.NewMultiKeychain(registryKeychain, cloudKeychain{})
+	default:
+		return fmt.Errorf("Invalid --keychain '%s': must be '%s' or '%s'", keychainMode, keychainDocker, keychainCloud)
+	}
+
+	// The named helper is asked first; hosts it has nothing for fall back
+	// to the keychain above.
+	if credentialHelper != "" {
+		program := "docker-credential-" + credentialHelper
+		if _, err := exec.LookPath(program); err != nil {
+			return fmt.Errorf("Invalid --credential-helper '%s': %v", credentialHelper, err)
+		}
+		registryKeychain = authn.NewMultiKeychain(helperKeychain{program: program}, registryKeychain)
+	}
+
+	if registryToken != "" {
+		if username != "" || password != "" || passwordStdin {
+			return errors.New("--registry-token cannot be combined with --username/--password")
+		}
+		registryAuth = &authn.Bearer{Token: registryToken}
+		return nil
+	}
+
+	if passwordStdin {
+		if password != "" {
+			return errors.New("--password and --password-stdin are mutually exclusive")
+		}
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("Failed to read password from stdin: %v", err)
+		}
+		password = strings.TrimRight(string(data), "\r\n")
+	}
+
+	if username == "" && password == "" {
+		return nil
+	}
+	if username == "" || password == "" {
+		return errors.New("--username and --password (or --password-stdin) must be given together")
+	}
+	registryAuth = &authn.Basic{Username: username, Password: password}
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+func TestDockerConfigWithoutConfigJSON(t *testing.T) {
+	empty := t.TempDir()
+	t.Cleanup(func() { dockerConfig, registryKeychain = "", authn.DefaultKeychain })
+
+	// The environment variable alone is no reason to fail.
+	t.Setenv("DOCKER_CONFIG", empty)
+	if err := configureAuth(); err != nil {
+		t.Fatalf("DOCKER_CONFIG without config.json: %v", err)
+	}
+	repo, _ := name.NewRepository("registry.example/app")
+	auth, err := registryKeychain.Resolve(repo)
+	if err != nil || auth != authn.Anonymous {
+		t.Errorf("resolved %v (%v), want anonymous", auth, err)
+	}
+
+	// An explicit --docker-config is.
+	dockerConfig = empty
+	if err := configureAuth(); err == nil {
+		t.Error("--docker-config without config.json was accepted")
+	}
+}
+
+func TestDockerConfigCredentials(t *testing.T) {
+	dir := t.TempDir()
+	// user:secret
+	config := `{"auths":{"registry.example":{"auth":"dXNlcjpzZWNyZXQ="}}}`
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DOCKER_CONFIG", "")
+	dockerConfig = dir
+	t.Cleanup(func() { dockerConfig, registryKeychain = "", authn.DefaultKeychain })
+	if err := configureAuth(); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, _ := name.NewRepository("registry.example/app")
+	auth, err := registryKeychain.Resolve(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := auth.Authorization()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Username != "user" || cfg.Password != "secret" {
+		t.Errorf("got %s:%s, want user:secret", cfg.Username, cfg.Password)
+	}
+}
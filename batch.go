@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+)
+
+// source argument that switches to reading "<source-image> <new-tag>..." lines from stdin
+const batchSource = "-"
+
+// --parallel: number of batch lines promoted concurrently
+var parallel int
+
+var (
+	// --abort-on-first-failure: start no further batch lines (or mirror
+	// tags) once one has failed
+	abortOnFailure bool
+	// --continue: process every entry despite failures, the default
+	continueOnFailure bool
+)
+
+// one input line and, once run, its buffered output and outcome
+type batchJob struct {
+	lineNo   int
+	line     string
+	out      *printer
+	tags     int
+	failed   []string
+	code     int
+	done     chan struct{}
+	parseErr bool
+	ran      bool
+}
+
+// Promote every line read from r. Blank lines and lines starting with '#'
+// are ignored. Up to --parallel lines run at once; each line's output is
+// buffered and flushed in input order. Returns the exit code of the first
+// failing line, or exitOK.
+func runBatch(ctx context.Context, r io.Reader) int {
+	var jobs []*batchJob
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		jobs = append(jobs, &batchJob{lineNo: lineNo, line: line, out: newBufferedPrinter(), done: make(chan struct{})})
+	}
+	if err := scanner.Err(); err != nil {
+		stdPrinter.printError(exitFailure, "", "", fmt.Sprintf("Failed to read stdin: %v", err))
+		return exitFailure
+	}
+
+	d := newDispatcher()
+	go func() {
+		for _, job := range jobs {
+			d.run(job.done, func() bool {
+				job.ran = true
+				job.run(ctx)
+				return job.code == exitOK
+			})
+		}
+	}()
+
+	var succeeded, failed, notRun int
+	var failures []string
+	code := exitOK
+	for _, job := range jobs {
+		<-job.done
+		if !job.ran {
+			notRun++
+			continue
+		}
+		job.out.flushTo(stdPrinter)
+		if code == exitOK {
+			code = job.code
+		}
+
+		if job.parseErr {
+			failed++
+			failures = append(failures, fmt.Sprintf("line %d", job.lineNo))
+			continue
+		}
+		succeeded += job.tags - len(job.failed)
+		failed += len(job.failed)
+		for _, tag := range job.failed {
+			failures = append(failures, fmt.Sprintf("line %d (%s -> %s)", job.lineNo, strings.Fields(job.line)[0], tag))
+		}
+	}
+
+	stdPrinter.printNotice(statusSummary, fmt.Sprintf("%d succeeded, %d failed%s", succeeded, failed, notRunNote(notRun, "line")))
+	if failed > 0 {
+		stdPrinter.printError(code, "", "", fmt.Sprintf("%d retags failed: %s", failed, strings.Join(failures, ", ")))
+	}
+	return code
+}
+
+// Starts jobs in input order, up to --parallel at once. With
+// --abort-on-first-failure no job is started once one has failed; those
+// already running are left to finish.
+type dispatcher struct {
+	sem    chan struct{}
+	failed atomic.Bool
+}
+
+func newDispatcher() *dispatcher {
+	return &dispatcher{sem: make(chan struct{}, parallel)}
+}
+
+// Wait for a free slot and run fn, which reports whether the job succeeded,
+// in its own goroutine. done is closed once it has returned, or at once if
+// the run was aborted and fn is never called.
+func (d *dispatcher) run(done chan struct{}, fn func() bool) {
+	d.sem <- struct{}{}
+	if abortOnFailure && d.failed.Load() {
+		<-d.sem
+		close(done)
+		return
+	}
+	go func() {
+		defer func() { <-d.sem }()
+		defer close(done)
+		if !fn() {
+			d.failed.Store(true)
+		}
+	}()
+}
+
+// ", 3 lines not attempted (--abort-on-first-failure)" for the summary, if any
+func notRunNote(n int, what string) string {
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", %d %s not attempted (--abort-on-first-failure)", n, plural(n, what))
+}
+
+// --abort-on-first-failure and --continue pick opposite behaviours.
+func checkFailureMode() {
+	if abortOnFailure && continueOnFailure {
+		fatalf("--abort-on-first-failure and --continue are mutually exclusive")
+	}
+}
+
+func (j *batchJob) run(ctx context.Context) {
+	fields := strings.Fields(j.line)
+	if len(fields) < 2 {
+		j.out.printError(exitUsage, "", "", fmt.Sprintf("Line %d: expected '<source-image> <new-tag>', got '%s'", j.lineNo, j.line))
+		j.parseErr = true
+		j.code = exitUsage
+		return
+	}
+	j.tags = len(fields) - 1
+	j.failed, j.code = promote(withPrinter(ctx, j.out), j.out, fields[0], fields[1:])
+}
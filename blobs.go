@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+var (
+	// --manifest-only: fail rather than upload any blob when copying across repositories
+	manifestOnly bool
+	// --require-blob-mount: fail before anything is fetched if the destination
+	// is on another registry, where blobs can't be mounted
+	requireBlobMount bool
+)
+
+// source -> destination registry pairs already noted by noteCrossRegistry
+var notedRegistries sync.Map
+
+// Say, once per pair of registries, that blobs will be uploaded in full.
+func noteCrossRegistry(out *printer, src sourceImage, dest name.Reference) {
+	from, to := src.ref.Context().RegistryStr(), dest.Context().RegistryStr()
+	if _, seen := notedRegistries.LoadOrStore(from+" "+to, true); seen {
+		return
+	}
+	out.printNotice(statusNote, fmt.Sprintf("Destination registry %s differs from the source's %s: blobs can't be mounted, so any the destination lacks are uploaded in full (see --require-blob-mount)", to, from))
+}
+
+// Blobs can only be mounted from a repository on the same registry; a copy
+// to another registry uploads every blob the destination lacks.
+func crossRegistry(src sourceImage, dest name.Reference) bool {
+	return src.ref != nil && src.ref.Context().RegistryStr() != dest.Context().RegistryStr()
+}
+
+var errBlobUpload = errors.New("a blob would have to be uploaded, so the repositories don't share storage (--manifest-only)")
+
+// blob transfers seen while writing one destination
+type blobStats struct {
+	mu       sync.Mutex
+	mounted  int
+	uploaded int
+	existing int
+}
+
+// observes blob uploads made through it, counting cross-repo mounts, full
+// uploads and blobs already present; with --manifest-only, a full upload is
+// refused before any data is sent
+type blobStatsTransport struct {
+	inner http.RoundTripper
+	stats *blobStats
+}
+
+func (t *blobStatsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	isUpload := req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/blobs/uploads/")
+	if isUpload && manifestOnly && req.URL.Query().Get("mount") == "" {
+		return nil, errBlobUpload
+	}
+
+	resp, err := t.inner.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	t.stats.mu.Lock()
+	defer t.stats.mu.Unlock()
+	switch {
+	case isUpload && resp.StatusCode == http.StatusCreated:
+		t.stats.mounted++
+	case isUpload && resp.StatusCode == http.StatusAccepted:
+		if manifestOnly {
+			resp.Body.Close()
+			return nil, errBlobUpload
+		}
+		t.stats.uploaded++
+	case req.Method == http.MethodHead && strings.Contains(req.URL.Path, "/blobs/") && resp.StatusCode == http.StatusOK:
+		t.stats.existing++
+	}
+	return resp, nil
+}
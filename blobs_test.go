@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ggcrregistry "github.com/google/go-containerregistry/pkg/registry"
+)
+
+func TestManifestOnlyRefusedUploadIsNotRetried(t *testing.T) {
+	// A registry whose repositories don't share storage: blobs pushed to
+	// one aren't found in another, and a mount request just opens a plain
+	// upload.
+	var uploads atomic.Int32
+	inner := ggcrregistry.New(ggcrregistry.Logger(log.New(io.Discard, "", 0)))
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead && strings.HasPrefix(r.URL.Path, "/v2/prod/") && strings.Contains(r.URL.Path, "/blobs/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/blobs/uploads/") {
+			uploads.Add(1)
+			r.URL.RawQuery = ""
+		}
+		inner.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	pushImage(t, host+"/dev/app:build-1")
+	manifestOnly, retryDelay = true, time.Millisecond
+	t.Cleanup(func() { manifestOnly, retryDelay = false, time.Second })
+
+	ctx := context.Background()
+	src, err := resolveSource(ctx, newBufferedPrinter(), host+"/dev/app:build-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploads.Store(0)
+	_, err = retagOne(ctx, newBufferedPrinter(), src, host+"/prod/app:release")
+	if !errors.Is(err, errBlobUpload) {
+		t.Fatalf("got error %v, want the refused upload", err)
+	}
+	// The image has two blobs, a layer and its config; the write may stop
+	// at the first refusal. Retrying would start their uploads again.
+	if n := uploads.Load(); n < 1 || n > 2 {
+		t.Errorf("%d upload requests, want one per blob at most", n)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker-credential-helpers/client"
+	"github.com/docker/docker-credential-helpers/credentials"
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// Supported values for --keychain
+const (
+	keychainDocker = "docker"
+	keychainCloud  = "cloud"
+)
+
+// --keychain: which keychain resolves credentials when none are given explicitly
+var keychainMode string
+
+// credential helpers (docker-credential-<name>) tried for cloud registry
+// hosts, in order; the first one installed is used
+var cloudHelpers = []struct {
+	host    *regexp.Regexp
+	helpers []string
+}{
+	{regexp.MustCompile(`^\d+\.dkr\.ecr(-fips)?\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`), []string{"ecr-login"}},
+	{regexp.MustCompile(`^([a-z0-9-]+\.)?gcr\.io$|^[a-z0-9-]+-docker\.pkg\.dev$`), []string{"gcloud", "gcr"}},
+	{regexp.MustCompile(`^[a-z0-9-]+\.azurecr\.(io|cn|us)$`), []string{"acr-env"}},
+}
+
+// keychain that picks the AWS ECR, Google (GCR/Artifact Registry) or Azure
+// ACR credential helper from the registry host, so CI doesn't need to log
+// in to the cloud registry beforehand
+type cloudKeychain struct{}
+
+// cloud registry hosts already warned about having no helper installed
+var missingHelperWarned sync.Map
+
+func (cloudKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	host := target.RegistryStr()
+	for _, c := range cloudHelpers {
+		if !c.host.MatchString(host) {
+			continue
+		}
+		programs := make([]string, len(c.helpers))
+		for i, helper := range c.helpers {
+			programs[i] = "docker-credential-" + helper
+			if _, err := exec.LookPath(programs[i]); err != nil {
+				continue
+			}
+			return helperAuth(programs[i], host)
+		}
+		// Resolve runs for every request, so each host is warned about once.
+		if _, warned := missingHelperWarned.LoadOrStore(host, true); !warned {
+			stdPrinter.printNotice(statusWarning, fmt.Sprintf("No credential helper for '%s' is installed (%s not found on PATH), so it is accessed anonymously (--keychain=%s)",
+				host, strings.Join(programs, ", "), keychainCloud))
+		}
+	}
+	return authn.Anonymous, nil
+}
+
+// keychain that asks one named credential helper, from --credential-helper,
+// for every registry host
+type helperKeychain struct {
+	program string
+}
+
+func (k helperKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	return helperAuth(k.program, target.RegistryStr())
+}
+
+// Runs "program get" for host. A helper that has nothing stored for the host
+// yields Anonymous, so the next keychain gets a chance.
+func helperAuth(program, host string) (authn.Authenticator, error) {
+	creds, err := client.Get(client.NewShellProgramFunc(program), host)
+	if credentials.IsErrCredentialsNotFound(err) {
+		return authn.Anonymous, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s failed for '%s': %v", program, host, err)
+	}
+	// Helpers store identity tokens under this username, as docker login does.
+	if creds.Username == "<token>" {
+		return authn.FromConfig(authn.AuthConfig{IdentityToken: creds.Secret}), nil
+	}
+	return authn.FromConfig(authn.AuthConfig{Username: creds.Username, Password: creds.Secret}), nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+func TestCloudKeychainWarnsOnceWithoutHelper(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	std := stdPrinter
+	stdPrinter = newBufferedPrinter()
+	t.Cleanup(func() { stdPrinter = std })
+
+	for _, host := range []string{"123456789012.dkr.ecr.us-east-1.amazonaws.com", "123456789012.dkr.ecr.us-east-1.amazonaws.com", "registry.example.com"} {
+		reg, err := name.NewRegistry(host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		auth, err := cloudKeychain{}.Resolve(reg)
+		if err != nil || auth != authn.Anonymous {
+			t.Errorf("%s: got %v, %v; want anonymous access", host, auth, err)
+		}
+	}
+	got := stdPrinter.stderr.(*lockedBuffer).String()
+	if n := strings.Count(got, "[WARNING]"); n != 1 || !strings.Contains(got, "docker-credential-ecr-login") {
+		t.Errorf("stderr %q, want one warning naming docker-credential-ecr-login", got)
+	}
+}
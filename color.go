@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+)
+
+// --no-color: never colorize the status prefixes
+var noColor bool
+
+// ANSI colors of the status prefixes; other prefixes stay plain
+var prefixColors = []struct {
+	prefix string
+	color  string
+}{
+	{"[OK]", "\x1b[32m"},
+	{"[FAIL]", "\x1b[31m"},
+	{"[SKIP]", "\x1b[33m"},
+}
+
+const colorReset = "\x1b[0m"
+
+// Colorize the text output's status prefixes on whichever of stdout and
+// stderr is a terminal, unless --no-color or NO_COLOR (https://no-color.org)
+// turns it off. The prefixes themselves are kept, so the text reads the same
+// once the escape codes are stripped, and logs and pipes get none at all.
+func setupColor() {
+	if noColor || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" || outputFormat != outputText {
+		return
+	}
+	if isTerminal(os.Stdout) {
+		stdPrinter.stdout = &colorWriter{w: os.Stdout}
+	}
+	if isTerminal(os.Stderr) {
+		stdPrinter.stderr = &colorWriter{w: os.Stderr}
+	}
+}
+
+// colors the status prefix at the start of each line written through it
+type colorWriter struct {
+	w  io.Writer
+	mu sync.Mutex
+	// the last write didn't end a line, so the next one doesn't start one
+	midLine bool
+}
+
+func (c *colorWriter) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var b bytes.Buffer
+	for rest := p; len(rest) > 0; {
+		line, after, found := bytes.Cut(rest, []byte("\n"))
+		if !c.midLine {
+			line = colorPrefix(&b, line)
+		}
+		b.Write(line)
+		if found {
+			b.WriteByte('\n')
+		}
+		c.midLine, rest = !found, after
+	}
+	if _, err := c.w.Write(b.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Write line's status prefix, if it has one, in color to b and return the
+// rest of the line.
+func colorPrefix(b *bytes.Buffer, line []byte) []byte {
+	for _, pc := range prefixColors {
+		if bytes.HasPrefix(line, []byte(pc.prefix)) {
+			b.WriteString(pc.color + pc.prefix + colorReset)
+			return line[len(pc.prefix):]
+		}
+	}
+	return line
+}
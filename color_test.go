@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestColorWriter(t *testing.T) {
+	const green, red, yellow = "\x1b[32m", "\x1b[31m", "\x1b[33m"
+	tests := []struct {
+		writes []string
+		want   string
+	}{
+		{[]string{"[OK] done\n"}, green + "[OK]" + colorReset + " done\n"},
+		{[]string{"[FAIL] a\n[SKIP] b\n"}, red + "[FAIL]" + colorReset + " a\n" + yellow + "[SKIP]" + colorReset + " b\n"},
+		{[]string{"[NOTE] plain\n"}, "[NOTE] plain\n"},
+		// A prefix later on a line isn't one.
+		{[]string{"[OK] part", "ial [OK]\n"}, green + "[OK]" + colorReset + " partial [OK]\n"},
+		{[]string{"\n[OK] a"}, "\n" + green + "[OK]" + colorReset + " a"},
+		{[]string{"[OK]"}, green + "[OK]" + colorReset},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		w := &colorWriter{w: &b}
+		for _, s := range tt.writes {
+			if n, err := w.Write([]byte(s)); n != len(s) || err != nil {
+				t.Errorf("Write(%q) = %d, %v", s, n, err)
+			}
+		}
+		if b.String() != tt.want {
+			t.Errorf("writes %q: got %q, want %q", tt.writes, b.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// --config: YAML file of defaults for the persistent flags
+var configPath string
+
+const (
+	defaultConfigName = ".docker-retag.yaml"
+	envPrefix         = "DOCKER_RETAG_"
+)
+
+// Fill in every persistent flag not given on the command line from Viper,
+// which reads its DOCKER_RETAG_<FLAG> environment variable, then the config
+// file. Precedence is therefore: flag, environment, config file, built-in
+// default.
+func applyConfig(cmd *cobra.Command) error {
+	v := viper.New()
+	v.SetEnvPrefix(strings.TrimSuffix(envPrefix, "_"))
+	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	v.AutomaticEnv()
+
+	path, explicit := configPath, configPath != ""
+	if !explicit {
+		if env := v.GetString("config"); env != "" {
+			path, explicit = env, true
+		} else if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, defaultConfigName)
+		}
+	}
+
+	if path != "" {
+		v.SetConfigFile(path)
+		v.SetConfigType("yaml")
+		err := v.ReadInConfig()
+		var perr viper.ConfigParseError
+		switch {
+		case errors.Is(err, os.ErrNotExist) && !explicit:
+			// The default config file is optional.
+		case errors.As(err, &perr):
+			return fmt.Errorf("Invalid config file '%s': %v", path, err)
+		case err != nil:
+			return fmt.Errorf("Failed to read config file '%s': %v", path, err)
+		}
+	}
+
+	// Only the file's keys are known yet, as no flags are bound.
+	flags := cmd.Root().PersistentFlags()
+	var unknown []string
+	for _, key := range v.AllKeys() {
+		if f := flags.Lookup(key); f == nil || f.Name == "config" {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("Unknown key(s) in config file '%s': %s", path, strings.Join(unknown, ", "))
+	}
+
+	var err error
+	flags.VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed || f.Name == "config" || !v.IsSet(f.Name) {
+			return
+		}
+		from := fmt.Sprintf("'%s' in config file '%s'", f.Name, path)
+		if env := envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_")); os.Getenv(env) != "" {
+			from = env
+		}
+		// A list from the file sets a repeatable flag once per element.
+		if list, isList := v.Get(f.Name).([]any); isList {
+			sv, repeatable := f.Value.(pflag.SliceValue)
+			if !repeatable {
+				err = fmt.Errorf("Invalid value for %s: it takes a single value, not a list", from)
+				return
+			}
+			items := make([]string, len(list))
+			for i, item := range list {
+				items[i] = fmt.Sprint(item)
+			}
+			if serr := sv.Replace(items); serr != nil {
+				err = fmt.Errorf("Invalid value '%s' for %s: %v", strings.Join(items, ", "), from, serr)
+			}
+			return
+		}
+		s := v.GetString(f.Name)
+		if serr := f.Value.Set(s); serr != nil {
+			err = fmt.Errorf("Invalid value '%s' for %s: %v", s, from, serr)
+		}
+	})
+	return err
+}
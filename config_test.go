@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// A command with one repeatable and one single-value persistent flag, read
+// from a config file holding content.
+func configTestCmd(t *testing.T, content string) (*cobra.Command, *[]string, *int) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	configPath = path
+	t.Cleanup(func() { configPath = "" })
+
+	var list []string
+	var n int
+	cmd := &cobra.Command{}
+	cmd.PersistentFlags().StringArrayVar(&list, "ca-cert", nil, "")
+	cmd.PersistentFlags().IntVar(&n, "retries", 3, "")
+	return cmd, &list, &n
+}
+
+func TestConfigListSetsRepeatableFlag(t *testing.T) {
+	cmd, list, n := configTestCmd(t, "ca-cert: [a.pem, b.pem]\nretries: 5\n")
+	if err := applyConfig(cmd); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.pem", "b.pem"}; !reflect.DeepEqual(*list, want) {
+		t.Errorf("ca-cert %q, want %q", *list, want)
+	}
+	if *n != 5 {
+		t.Errorf("retries %d, want 5", *n)
+	}
+}
+
+func TestConfigListRejectedForSingleValueFlag(t *testing.T) {
+	cmd, _, _ := configTestCmd(t, "retries: [1, 2]\n")
+	if err := applyConfig(cmd); err == nil {
+		t.Error("a list for --retries was accepted")
+	}
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	tests := []struct {
+		name      string
+		flag, env string
+		want      int
+	}{
+		{"file", "", "", 5},
+		{"environment over file", "", "7", 7},
+		{"flag over environment", "9", "7", 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, _, n := configTestCmd(t, "retries: 5\n")
+			if tt.env != "" {
+				t.Setenv("DOCKER_RETAG_RETRIES", tt.env)
+			}
+			if tt.flag != "" {
+				if err := cmd.PersistentFlags().Set("retries", tt.flag); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := applyConfig(cmd); err != nil {
+				t.Fatal(err)
+			}
+			if *n != tt.want {
+				t.Errorf("retries %d, want %d", *n, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigEnvironmentOverridesFileList(t *testing.T) {
+	cmd, list, _ := configTestCmd(t, "ca-cert: [a.pem, b.pem]\n")
+	t.Setenv("DOCKER_RETAG_CA_CERT", "c.pem")
+	if err := applyConfig(cmd); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"c.pem"}; !reflect.DeepEqual(*list, want) {
+		t.Errorf("ca-cert %q, want %q", *list, want)
+	}
+}
+
+func TestConfigFileErrors(t *testing.T) {
+	cmd, _, _ := configTestCmd(t, "retries: 5\nno-such-flag: 1\n")
+	if err := applyConfig(cmd); err == nil || !strings.Contains(err.Error(), "no-such-flag") {
+		t.Errorf("got error %v, want the unknown key reported", err)
+	}
+
+	configPath = filepath.Join(t.TempDir(), "missing.yaml")
+	if err := applyConfig(cmd); err == nil {
+		t.Error("a missing --config file was accepted")
+	}
+
+	// The default file is optional.
+	configPath = ""
+	t.Setenv("HOME", t.TempDir())
+	if err := applyConfig(cmd); err != nil {
+		t.Errorf("a missing default config file failed: %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/spf13/cobra"
+)
+
+// outcome of the copy subcommand
+type copyResult struct {
+	Source         string          `json:"source"`
+	Destination    string          `json:"destination"`
+	Digest         string          `json:"digest"`
+	MediaType      types.MediaType `json:"media_type"`
+	PreviousDigest *string         `json:"previous_digest"`
+	Status         string          `json:"status"`
+}
+
+func newCopyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "copy <src-ref> <dst-ref>",
+		Short: "Copy an image or index to an arbitrary reference, like crane copy",
+		Long: `Copy whatever <src-ref> points to, an image or a whole manifest list, to
+<dst-ref>, which may be in another repository or registry and may be a tag
+or a digest (repo@sha256:..., which must be the source's digest). The copy
+is pinned to the digest the source resolved to, and skipped if the
+destination already points to it. With --platform, only that platform's
+image of a manifest list is copied.
+
+Unlike a retag, there are no bare tags, batch lines or promotion checks:
+both references are taken literally.`,
+		Args: cobra.ExactArgs(2),
+		Run:  copyImage,
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Report whether the copy would happen without making changes")
+	return cmd
+}
+
+func copyImage(cmd *cobra.Command, args []string) {
+	parseCommonFlags()
+	rejectVariableOutput("copy")
+
+	src, err := name.ParseReference(args[0], nameOptions()...)
+	if err != nil {
+		fatalf("Invalid source reference '%s': %v", args[0], err)
+	}
+	dst, err := name.ParseReference(args[1], nameOptions()...)
+	if err != nil {
+		fatalf("Invalid destination reference '%s': %v", args[1], err)
+	}
+
+	ctx, cancel := setupRegistry()
+	defer cancel()
+
+	// Resolve first, so the copy is pinned even if the source tag moves.
+	var digest v1.Hash
+	var mediaType types.MediaType
+	err = withRetry(ctx, stdPrinter, "Fetching source image", func() error {
+		desc, err := registryClient.Get(ctx, src)
+		if err != nil {
+			return err
+		}
+		digest, mediaType = desc.Digest, desc.MediaType
+		if desc.MediaType.IsIndex() && platform != nil {
+			img, err := desc.Image()
+			if err != nil {
+				return err
+			}
+			if digest, err = img.Digest(); err != nil {
+				return err
+			}
+			mediaType, err = img.MediaType()
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		exitf(registryExitCode(err, exitSourceNotFound), "Source image '%s' not found or inaccessible: %v", args[0], err)
+	}
+	if d, ok := dst.(name.Digest); ok && d.DigestStr() != digest.String() {
+		fatalf("Destination digest %s does not match the source, which resolved to %s", d.DigestStr(), digest)
+	}
+
+	res := copyResult{Source: src.String(), Destination: dst.String(), Digest: digest.String(), MediaType: mediaType}
+	var prev *v1.Descriptor
+	err = withRetry(ctx, stdPrinter, "Fetching destination", func() (err error) {
+		prev, err = registryClient.Head(ctx, dst)
+		return err
+	})
+	switch {
+	case err == nil:
+		d := prev.Digest.String()
+		res.PreviousDigest = &d
+	case isNotFound(err):
+		// created below
+	default:
+		exitf(registryExitCode(err, exitFailure), "Could not check whether '%s' exists: %v", dst, err)
+	}
+
+	switch {
+	case prev != nil && prev.Digest == digest:
+		res.Status = statusUnchanged
+	case dryRun && prev != nil:
+		res.Status = statusWouldUpdate
+	case dryRun:
+		res.Status = statusWouldCreate
+	default:
+		from := src.Context().Digest(digest.String()).String()
+		err = withRetry(ctx, stdPrinter, fmt.Sprintf("Copying to '%s'", dst), func() error {
+			return registryClient.Copy(ctx, from, dst.String(), writeOptions{})
+		})
+		if err != nil && isImmutableTagError(err) {
+			exitf(exitImmutable, "Destination '%s' is immutable in this registry; use a new tag or disable tag immutability for the repository (%v)", dst, err)
+		}
+		if err != nil {
+			exitf(registryExitCode(err, exitWriteFailed), "Failed to copy '%s' to '%s': %v", src, dst, err)
+		}
+		res.Status = statusCreated
+		if prev != nil {
+			res.Status = statusUpdated
+		}
+	}
+	stdPrinter.printCopy(res)
+}
+
+func (p *printer) printCopy(r copyResult) {
+	if quiet {
+		return
+	}
+	if outputFormat == outputJSON {
+		writeJSON(p.stdout, r)
+		return
+	}
+	switch r.Status {
+	case statusUnchanged:
+		fmt.Fprintf(p.stdout, "[OK] '%s' already points to %s; nothing was copied.\n", r.Destination, r.Digest)
+	case statusWouldCreate:
+		fmt.Fprintf(p.stdout, "[DRY-RUN] Would copy %s (%s) to '%s'.\n", r.Digest, r.MediaType, r.Destination)
+	case statusWouldUpdate:
+		fmt.Fprintf(p.stdout, "[DRY-RUN] Would copy %s (%s) to '%s', replacing %s.\n", r.Digest, r.MediaType, r.Destination, *r.PreviousDigest)
+	case statusUpdated:
+		fmt.Fprintf(p.stdout, "[OK] Copied %s (%s) to '%s', replacing %s.\n", r.Digest, r.MediaType, r.Destination, *r.PreviousDigest)
+	default:
+		fmt.Fprintf(p.stdout, "[OK] Copied %s (%s) to '%s'.\n", r.Digest, r.MediaType, r.Destination)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/spf13/cobra"
+)
+
+// --yes for the delete subcommand
+var deleteYes bool
+
+// outcome of the delete subcommand
+type deleteResult struct {
+	Reference string `json:"reference"`
+	Digest    string `json:"digest"`
+	Target    string `json:"target"` // "tag" or "manifest"
+	Status    string `json:"status"`
+}
+
+func newDeleteCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "delete <image>",
+		Short: "Delete a tag, or a manifest by digest, from a remote repository",
+		Long: `Delete a remote tag (e.g., registry/app:stale) or, given a digest reference
+(registry/app@sha256:...), the manifest itself along with every tag pointing
+to it. Deleting a tag leaves the manifest and its other tags in place, but
+some registries only support deleting by digest.
+
+When stdin is a terminal, the deletion must be confirmed interactively
+unless --yes is given.`,
+		Args: cobra.ExactArgs(1),
+		Run:  deleteImage,
+	}
+	cmd.Flags().BoolVarP(&deleteYes, "yes", "y", false, "Delete without asking for confirmation")
+	return cmd
+}
+
+func deleteImage(cmd *cobra.Command, args []string) {
+	parseCommonFlags()
+	rejectVariableOutput("delete")
+
+	ref, err := name.ParseReference(args[0], nameOptions()...)
+	if err != nil {
+		fatalf("Invalid image reference '%s': %v", args[0], err)
+	}
+	target := "manifest"
+	if _, ok := ref.(name.Tag); ok {
+		target = "tag"
+	}
+
+	ctx, cancel := setupRegistry()
+	defer cancel()
+
+	// Resolve first, so a missing reference is reported as such and the
+	// digest that was removed is known.
+	var desc *v1.Descriptor
+	err = withRetry(ctx, stdPrinter, "Fetching image", func() (err error) {
+		desc, err = registryClient.Head(ctx, ref)
+		return err
+	})
+	if err != nil {
+		exitf(registryExitCode(err, exitSourceNotFound), "Image '%s' not found or inaccessible: %v", args[0], err)
+	}
+
+	if !deleteYes && isTerminal(os.Stdin) && !confirm(fmt.Sprintf("Delete %s '%s' (%s)?", target, ref, desc.Digest)) {
+		exitf(exitFailure, "Deletion of '%s' not confirmed", ref)
+	}
+
+	err = withRetry(ctx, stdPrinter, fmt.Sprintf("Deleting '%s'", ref), func() error {
+		return registryClient.Delete(ctx, ref)
+	})
+	if err != nil {
+		hint := ""
+		if target == "tag" {
+			hint = fmt.Sprintf(" (if the registry only deletes by digest, use %s, which removes every tag pointing to it)", digestRef(ref.Context(), desc.Digest))
+		}
+		exitf(registryExitCode(err, exitWriteFailed), "Failed to delete %s '%s': %v%s", target, ref, err, hint)
+	}
+
+	stdPrinter.printDelete(deleteResult{Reference: ref.String(), Digest: desc.Digest.String(), Target: target, Status: statusDeleted})
+}
+
+// Ask a yes/no question on stderr and read the answer from stdin.
+func confirm(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func (p *printer) printDelete(r deleteResult) {
+	if quiet {
+		return
+	}
+	if outputFormat == outputJSON {
+		writeJSON(p.stdout, r)
+		return
+	}
+	if r.Target == "tag" {
+		fmt.Fprintf(p.stdout, "[OK] Deleted tag '%s'; manifest %s was left in place.\n", r.Reference, r.Digest)
+		return
+	}
+	fmt.Fprintf(p.stdout, "[OK] Deleted manifest '%s' and every tag pointing to it.\n", r.Reference)
+}
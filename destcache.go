@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// --cache-destinations: check destination tags against one tag listing per
+// repository instead of fetching each of them
+var cacheDestinations bool
+
+// the tags of one destination repository, listed on first use
+type repoListing struct {
+	once sync.Once
+	tags map[string]bool
+	err  error
+}
+
+var (
+	// destination repositories by name, to *repoListing
+	destListings sync.Map
+	// digests of destination tags looked up or written during this run, by
+	// full reference, to v1.Hash
+	destDigests sync.Map
+)
+
+// With --cache-destinations, the digest ref points to and whether that is
+// known: a zero digest means the repository's listing doesn't have the tag.
+// A tag that is listed costs a HEAD request instead of a manifest and config
+// fetch. Anything that can't be answered this way, including a failed
+// listing, is left to the normal destination fetch. So is a listed tag under
+// --platform: HEAD gives its manifest list's digest, never the platform's.
+func cachedDestination(ctx context.Context, out *printer, ref name.Tag) (v1.Hash, bool) {
+	if !cacheDestinations {
+		return v1.Hash{}, false
+	}
+	if d, ok := destDigests.Load(ref.String()); ok {
+		return d.(v1.Hash), true
+	}
+
+	l, _ := destListings.LoadOrStore(ref.Context().String(), &repoListing{})
+	listing := l.(*repoListing)
+	listing.once.Do(func() {
+		var tags []string
+		listing.err = withRetry(ctx, out, fmt.Sprintf("Listing tags in '%s'", ref.Context()), func() (err error) {
+			tags, err = registryClient.List(ctx, ref.Context())
+			return err
+		})
+		// A repository that doesn't exist yet has no tags.
+		if isNotFound(listing.err) {
+			listing.err = nil
+		}
+		listing.tags = make(map[string]bool, len(tags))
+		for _, tag := range tags {
+			listing.tags[tag] = true
+		}
+	})
+	if listing.err != nil {
+		return v1.Hash{}, false
+	}
+	if !listing.tags[ref.TagStr()] {
+		return v1.Hash{}, true
+	}
+	if platform != nil {
+		return v1.Hash{}, false
+	}
+
+	var desc *v1.Descriptor
+	err := withRetry(ctx, out, fmt.Sprintf("Fetching destination '%s'", ref.TagStr()), func() (err error) {
+		desc, err = registryClient.Head(ctx, ref)
+		return err
+	})
+	if err != nil {
+		return v1.Hash{}, false
+	}
+	destDigests.Store(ref.String(), desc.Digest)
+	return desc.Digest, true
+}
+
+// Record that this run pointed ref to digest, so later lines see the write.
+func rememberDestination(ref name.Tag, digest v1.Hash) {
+	if cacheDestinations {
+		destDigests.Store(ref.String(), digest)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	ggcrregistry "github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// requests a test registry served, by method and path
+type requestCounts struct {
+	mu sync.Mutex
+	n  map[string]int
+}
+
+func (c *requestCounts) add(method, path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.n[method+" "+path]++
+}
+
+func (c *requestCounts) get(method, path string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.n[method+" "+path]
+}
+
+func (c *requestCounts) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.n = map[string]int{}
+}
+
+// Like newTestRegistry, but also count the requests it serves.
+func newCountingRegistry(t *testing.T) (string, *requestCounts) {
+	t.Helper()
+	counts := &requestCounts{n: map[string]int{}}
+	inner := ggcrregistry.New(ggcrregistry.Logger(log.New(io.Discard, "", 0)))
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		counts.add(r.Method, r.URL.Path)
+		inner.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return strings.TrimPrefix(s.URL, "http://"), counts
+}
+
+func TestCachedDestinationRequests(t *testing.T) {
+	tests := []struct {
+		name      string
+		platform  *v1.Platform
+		wantHeads int
+		wantGets  int
+	}{
+		// A listed tag already pointing to the source costs one HEAD.
+		{"image", nil, 1, 0},
+		// Under --platform the HEAD can't tell, so only the full fetch is made.
+		{"platform", &v1.Platform{OS: "linux", Architecture: "arm64"}, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, counts := newCountingRegistry(t)
+			pushIndex(t, host+"/app:build-1")
+			retagForTest(t, host+"/app:build-1", "prod")
+			cacheDestinations, platform = true, tt.platform
+			t.Cleanup(func() { cacheDestinations, platform = false, nil })
+
+			counts.reset()
+			res := retagForTest(t, host+"/app:build-1", "prod")
+			if res.Status != statusUnchanged {
+				t.Errorf("status %q, want %q", res.Status, statusUnchanged)
+			}
+			if n := counts.get(http.MethodGet, "/v2/app/tags/list"); n != 1 {
+				t.Errorf("listed tags %d times, want once", n)
+			}
+			if n := counts.get(http.MethodHead, "/v2/app/manifests/prod"); n != tt.wantHeads {
+				t.Errorf("HEAD of the destination %d times, want %d", n, tt.wantHeads)
+			}
+			if n := counts.get(http.MethodGet, "/v2/app/manifests/prod"); n != tt.wantGets {
+				t.Errorf("GET of the destination %d times, want %d", n, tt.wantGets)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+var (
+	showDiff bool
+	// --compare-layers: list the shared and differing layers of a moved tag
+	compareLayers bool
+)
+
+// which layers a tag's current image and the source have in common
+type layerComparison struct {
+	Shared          []string `json:"shared"`
+	SourceOnly      []string `json:"source_only"`
+	DestinationOnly []string `json:"destination_only"`
+	// leading (base) layers identical on both sides, in order
+	CommonBase int `json:"common_base"`
+}
+
+// Compare the image a destination tag points to with the source, as
+// "field: change" lines reading destination -> source. For a manifest list
+// the default (or --platform) image of each side is compared. An empty
+// result means config and layers are identical, e.g. when only manifest
+// annotations differ.
+func imageDiff(ctx context.Context, src sourceImage, destRef name.Reference) ([]string, error) {
+	srcImg, err := diffSourceImage(ctx, src)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read source image: %v", err)
+	}
+	destImg, err := registryClient.Image(ctx, destRef)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read destination image: %v", err)
+	}
+	before, err := destImg.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read destination config: %v", err)
+	}
+	after, err := srcImg.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read source config: %v", err)
+	}
+	beforeLayers, err := layerDigests(destImg)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read destination layers: %v", err)
+	}
+	afterLayers, err := layerDigests(srcImg)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read source layers: %v", err)
+	}
+
+	var lines []string
+	changed := func(field, a, b string) {
+		if a != b {
+			lines = append(lines, fmt.Sprintf("%s: %s -> %s", field, a, b))
+		}
+	}
+	changed("Created", formatTime(before.Created.Time), formatTime(after.Created.Time))
+	changed("Platform", before.OS+"/"+before.Architecture, after.OS+"/"+after.Architecture)
+	changed("Entrypoint", formatList(before.Config.Entrypoint), formatList(after.Config.Entrypoint))
+	changed("Cmd", formatList(before.Config.Cmd), formatList(after.Config.Cmd))
+	changed("WorkingDir", before.Config.WorkingDir, after.Config.WorkingDir)
+	changed("User", before.Config.User, after.Config.User)
+	lines = append(lines, diffSets("Env", before.Config.Env, after.Config.Env)...)
+	lines = append(lines, diffSets("Label", formatLabels(before.Config.Labels), formatLabels(after.Config.Labels))...)
+	changed("Layers", fmt.Sprint(len(beforeLayers)), fmt.Sprint(len(afterLayers)))
+	lines = append(lines, diffSets("Layer", beforeLayers, afterLayers)...)
+	return lines, nil
+}
+
+// Compare the layer digests of the image a destination tag points to with
+// the source's, bottom layer first, like imageDiff. A long common base means
+// only the top of the image changed; none means the base image moved.
+func layerDiff(ctx context.Context, src sourceImage, destRef name.Reference) (*layerComparison, error) {
+	srcImg, err := diffSourceImage(ctx, src)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read source image: %v", err)
+	}
+	destImg, err := registryClient.Image(ctx, destRef)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read destination image: %v", err)
+	}
+	before, err := layerDigests(destImg)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read destination layers: %v", err)
+	}
+	after, err := layerDigests(srcImg)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read source layers: %v", err)
+	}
+
+	c := &layerComparison{Shared: []string{}, SourceOnly: []string{}, DestinationOnly: []string{}}
+	for c.CommonBase < min(len(before), len(after)) && before[c.CommonBase] == after[c.CommonBase] {
+		c.CommonBase++
+	}
+	inBefore := make(map[string]bool, len(before))
+	for _, d := range before {
+		inBefore[d] = true
+	}
+	inAfter := make(map[string]bool, len(after))
+	for _, d := range after {
+		inAfter[d] = true
+		if inBefore[d] {
+			c.Shared = append(c.Shared, d)
+		} else {
+			c.SourceOnly = append(c.SourceOnly, d)
+		}
+	}
+	for _, d := range before {
+		if !inAfter[d] {
+			c.DestinationOnly = append(c.DestinationOnly, d)
+		}
+	}
+	return c, nil
+}
+
+// e.g. "2 shared, 1 only in source, 1 only in destination; the bottom 2 layers match, ..."
+func (c *layerComparison) String() string {
+	str := fmt.Sprintf("%d shared, %d only in source, %d only in destination", len(c.Shared), len(c.SourceOnly), len(c.DestinationOnly))
+	if c.CommonBase == 0 {
+		return str + "; the bottom layer differs, so the base image moved"
+	}
+	if c.CommonBase == 1 {
+		return str + "; the bottom layer matches, so the changes sit on a shared base"
+	}
+	return str + fmt.Sprintf("; the bottom %d layers match, so the changes sit on a shared base", c.CommonBase)
+}
+
+// The source as a single image: the pinned remote digest, or for a local
+// source the image itself or its default (or --platform) child.
+func diffSourceImage(ctx context.Context, src sourceImage) (v1.Image, error) {
+	if src.artifact == nil {
+		return registryClient.Image(ctx, src.ref.Context().Digest(src.digest.String()))
+	}
+	if img, ok := src.artifact.(v1.Image); ok {
+		return img, nil
+	}
+	idx := src.artifact.(v1.ImageIndex)
+	manifest, err := idx.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	want := v1.Platform{OS: "linux", Architecture: "amd64"}
+	if platform != nil {
+		want = *platform
+	}
+	for _, child := range manifest.Manifests {
+		if child.Platform != nil && child.Platform.Satisfies(want) {
+			return idx.Image(child.Digest)
+		}
+	}
+	if len(manifest.Manifests) == 0 {
+		return nil, fmt.Errorf("manifest list is empty")
+	}
+	return idx.Image(manifest.Manifests[0].Digest)
+}
+
+func layerDigests(img v1.Image) ([]string, error) {
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, err
+	}
+	digests := make([]string, 0, len(layers))
+	for _, l := range layers {
+		d, err := l.Digest()
+		if err != nil {
+			return nil, err
+		}
+		digests = append(digests, d.String())
+	}
+	return digests, nil
+}
+
+// "- x" for entries only in before and "+ x" for entries only in after,
+// keeping the order each side lists them in
+func diffSets(field string, before, after []string) []string {
+	in := func(list []string) map[string]bool {
+		m := make(map[string]bool, len(list))
+		for _, s := range list {
+			m[s] = true
+		}
+		return m
+	}
+	inBefore, inAfter := in(before), in(after)
+	var lines []string
+	for _, s := range before {
+		if !inAfter[s] {
+			lines = append(lines, fmt.Sprintf("%s: - %s", field, s))
+		}
+	}
+	for _, s := range after {
+		if !inBefore[s] {
+			lines = append(lines, fmt.Sprintf("%s: + %s", field, s))
+		}
+	}
+	return lines
+}
+
+func formatList(list []string) string {
+	if len(list) == 0 {
+		return "(none)"
+	}
+	return "[" + strings.Join(list, " ") + "]"
+}
+
+// labels as sorted key=value entries
+func formatLabels(labels map[string]string) []string {
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return pairs
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/spf13/cobra"
+)
+
+// outcome of the digest subcommand
+type digestResult struct {
+	Reference string `json:"reference"`
+	Digest    string `json:"digest"`
+}
+
+func newDigestCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "digest <image>",
+		Short: "Print the digest a reference resolves to",
+		Long: `Print the digest (sha256:...) a remote reference resolves to and nothing
+else, like 'crane digest' but with docker-retag's auth and transport flags.
+Only the manifest is fetched. For a manifest list, the digest of the list is
+printed, or with --platform the digest of the matching image.`,
+		Args: cobra.ExactArgs(1),
+		Run:  printDigest,
+	}
+}
+
+func printDigest(cmd *cobra.Command, args []string) {
+	parseCommonFlags()
+
+	ref, err := name.ParseReference(args[0], nameOptions()...)
+	if err != nil {
+		fatalf("Invalid image reference '%s': %v", args[0], err)
+	}
+
+	ctx, cancel := setupRegistry()
+	defer cancel()
+
+	var digest v1.Hash
+	err = withRetry(ctx, stdPrinter, "Fetching image", func() error {
+		desc, err := registryClient.Get(ctx, ref)
+		if err != nil {
+			return err
+		}
+		digest = desc.Digest
+		if desc.MediaType.IsIndex() && platform != nil {
+			img, err := desc.Image()
+			if err != nil {
+				return err
+			}
+			digest, err = img.Digest()
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		exitf(registryExitCode(err, exitSourceNotFound), "Image '%s' not found or inaccessible: %v", args[0], err)
+	}
+
+	switch outputFormat {
+	case outputJSON:
+		writeJSON(stdPrinter.stdout, digestResult{Reference: ref.String(), Digest: digest.String()})
+		return
+	case outputEnv:
+		writeEnv(stdPrinter.stdout, [][2]string{{"REFERENCE", ref.String()}, {"DIGEST", digest.String()}})
+		return
+	case outputGitHub:
+		writeGitHubOutputs(stdPrinter.github, [][2]string{{"reference", ref.String()}, {"digest", digest.String()}})
+	}
+	fmt.Fprintln(stdPrinter.stdout, digest)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Write DOCKER_RETAG_NAME='value' lines that a shell can eval. Values are
+// single-quoted, so nothing in them is expanded. The names don't collide with
+// the DOCKER_RETAG_<FLAG> variables read by applyConfig.
+func writeEnv(w io.Writer, vars [][2]string) {
+	for _, v := range vars {
+		fmt.Fprintf(w, "%s%s=%s\n", envPrefix, v[0], shellQuote(v[1]))
+	}
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// The variables for one successful result. DIGEST is what the destination
+// points to (the annotated manifest with --annotation, the manifest list with
+// --platform). A skipped tag wasn't moved, so like a failed one it sets none.
+func (p *printer) printEnvResult(r *retagResult) {
+	if r.Status == statusSkipped {
+		return
+	}
+	previous := ""
+	if r.PreviousDigest != nil {
+		previous = *r.PreviousDigest
+	}
+	writeEnv(p.stdout, [][2]string{
+		{"SOURCE", r.Source},
+		{"SOURCE_DIGEST", r.SourceDigest},
+		{"TAG", r.Tag},
+		{"DESTINATION", r.Destination},
+		{"DIGEST", writtenDigest(r.src).String()},
+		{"REF", digestRef(r.destRepo, writtenDigest(r.src))},
+		{"PREVIOUS_DIGEST", previous},
+		{"ACTION", r.Status},
+	})
+}
+
+// Subcommands whose output doesn't map onto variables refuse --output=env
+// and --output=github.
+func rejectVariableOutput(command string) {
+	if outputFormat == outputEnv || outputFormat == outputGitHub {
+		fatalf("--output=%s is not supported by '%s': use %s or %s", outputFormat, command, outputText, outputJSON)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"", "''"},
+		{"sha256:abc", "'sha256:abc'"},
+		{"it's", `'it'\''s'`},
+		{"$HOME `id` \"x\"", "'$HOME `id` \"x\"'"},
+		{"two\nlines", "'two\nlines'"},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.want {
+			t.Errorf("shellQuote(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// Results of retagging repo:build-1 to prod: created, and skipped for each
+// reason except missing-source, which reportMissingSource builds itself.
+func skippedResults(t *testing.T) (created *retagResult, skipped []*retagResult) {
+	t.Helper()
+	ref := name.MustParseReference("registry.example.com/app:build-1")
+	dest := name.MustParseReference("registry.example.com/app:prod")
+	src := sourceImage{str: ref.String(), ref: ref, digest: fakeDigest("build-1"), target: fakeDigest("build-1")}
+
+	created = newResult(src, "prod", dest)
+	created.Status = statusCreated
+	for _, reason := range []string{skipIfNewer, skipLabelFilter} {
+		r := newResult(src, "prod", dest)
+		r.setPrevious(fakeDigest("build-0"), time.Time{})
+		r.skip(reason)
+		skipped = append(skipped, r)
+	}
+	return created, skipped
+}
+
+func TestEnvResultSkippedSetsNothing(t *testing.T) {
+	outputFormat = outputEnv
+	t.Cleanup(func() { outputFormat = outputText })
+
+	created, skipped := skippedResults(t)
+	out := newBufferedPrinter()
+	out.printResult(created)
+	if got := out.stdout.(*lockedBuffer).String(); !strings.Contains(got, "DOCKER_RETAG_DIGEST='"+fakeDigest("build-1").String()+"'") {
+		t.Errorf("created tag printed %q, want its DIGEST", got)
+	}
+
+	for _, r := range skipped {
+		out := newBufferedPrinter()
+		out.printResult(r)
+		if got := out.stdout.(*lockedBuffer).String(); got != "" {
+			t.Errorf("tag skipped for %s printed %q, want nothing", *r.SkipReason, got)
+		}
+	}
+
+	out = newBufferedPrinter()
+	reportMissingSource(out, "registry.example.com/app:build-1", []string{"prod"})
+	if got := out.stdout.(*lockedBuffer).String(); got != "" {
+		t.Errorf("tag skipped for a missing source printed %q, want nothing", got)
+	}
+}
+
+func TestEnvResultUnderPlatformIsTheList(t *testing.T) {
+	outputFormat = outputEnv
+	t.Cleanup(func() { outputFormat = outputText })
+
+	ref := name.MustParseReference("registry.example.com/app:multi")
+	dest := name.MustParseReference("registry.example.com/app:prod")
+	src := sourceImage{str: ref.String(), ref: ref, digest: fakeDigest("arm64"), target: fakeDigest("arm64"), list: fakeDigest("list")}
+	r := newResult(src, "prod", dest)
+	r.Status = statusCreated
+
+	out := newBufferedPrinter()
+	out.printResult(r)
+	got := out.stdout.(*lockedBuffer).String()
+	for _, want := range []string{
+		"DOCKER_RETAG_DIGEST='" + fakeDigest("list").String() + "'\n",
+		"DOCKER_RETAG_REF='registry.example.com/app@" + fakeDigest("list").String() + "'\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("printed %q, want %q", got, want)
+		}
+	}
+}
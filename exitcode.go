@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// Process exit codes, so CI can tell failure categories apart. When several
+// tags fail for different reasons, the code of the first failure is used.
+const (
+	exitOK             = 0
+	exitFailure        = 1 // any failure not covered below
+	exitUsage          = 2 // invalid arguments or flags
+	exitSourceNotFound = 3
+	exitAuth           = 4   // authentication or authorization denied
+	exitNetwork        = 5   // network/transport failure or timeout
+	exitWriteFailed    = 6   // the destination tag could not be written
+	exitImmutable      = 7   // the registry refused to overwrite an immutable tag
+	exitInterrupted    = 130 // cancelled by SIGINT or SIGTERM, as shells report it
+)
+
+// shown at the end of --help
+const exitCodeHelp = `Exit codes:
+  0  success
+  1  other failure
+  2  invalid arguments
+  3  source image not found
+  4  authentication or authorization failure
+  5  network or transport error (including timeouts)
+  6  tag write failure
+  7  destination tag is immutable in the registry
+  130  interrupted by SIGINT or SIGTERM`
+
+// error carrying the exit code the process should report for it
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+func withExitCode(code int, err error) error {
+	return &exitError{code: code, err: err}
+}
+
+// exit code attached to err, or exitFailure if none was
+func exitCode(err error) int {
+	var eerr *exitError
+	if errors.As(err, &eerr) {
+		return eerr.code
+	}
+	return exitFailure
+}
+
+// Categorize a registry error by inspecting the transport error: auth and
+// network failures have their own codes; anything else gets fallback.
+func registryExitCode(err error, fallback int) int {
+	if isAuthError(err) {
+		return exitAuth
+	}
+	if isRetryable(err) || errors.Is(err, context.DeadlineExceeded) {
+		return exitNetwork
+	}
+	return fallback
+}
+
+func isAuthError(err error) bool {
+	var terr *transport.Error
+	if !errors.As(err, &terr) {
+		return false
+	}
+	if terr.StatusCode == http.StatusUnauthorized || terr.StatusCode == http.StatusForbidden {
+		return true
+	}
+	for _, d := range terr.Errors {
+		if d.Code == transport.UnauthorizedErrorCode || d.Code == transport.DeniedErrorCode {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether the registry refused a write because the tag is immutable, e.g.
+// ECR's TAG_INVALID "cannot be overwritten because the repository is
+// immutable", Artifact Registry's immutable tags or Harbor's immutability
+// rules. Registries only say so in the message, hence the text match.
+func isImmutableTagError(err error) bool {
+	var terr *transport.Error
+	if !errors.As(err, &terr) {
+		return false
+	}
+	return strings.Contains(strings.ToLower(terr.Error()), "immutable")
+}
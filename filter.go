@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+var (
+	labelFilterFlags []string
+
+	// parsed from --label-filter; every entry must match the source's config labels
+	labelFilters map[string]string
+)
+
+// Parse repeated --label-filter key=value flags.
+func parseLabelFilters() error {
+	if len(labelFilterFlags) == 0 {
+		return nil
+	}
+	labelFilters = make(map[string]string, len(labelFilterFlags))
+	for _, kv := range labelFilterFlags {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("Invalid label filter '%s': must be key=value", kv)
+		}
+		labelFilters[key] = value
+	}
+	return nil
+}
+
+// Report whether the labels satisfy every --label-filter. An image without a
+// readable config has no labels, so it only matches when no filter is set.
+func matchesLabelFilters(labels map[string]string) bool {
+	for key, want := range labelFilters {
+		if got, ok := labels[key]; !ok || got != want {
+			return false
+		}
+	}
+	return true
+}
+
+// the filters as "k=v, k=v" for messages, in a stable order
+func formatLabelFilters() string {
+	return strings.Join(formatLabels(labelFilters), ", ")
+}
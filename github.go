@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// With --output=github and no $GITHUB_OUTPUT, outputs are set with the
+// ::set-output workflow command that older runners read from stdout.
+var legacySetOutput bool
+
+// Point stdPrinter's step outputs at the file GitHub Actions names in
+// $GITHUB_OUTPUT. It is opened once, for appending, so buffered batch
+// output can be flushed into it in input order.
+func setupGitHubOutput() {
+	path := os.Getenv("GITHUB_OUTPUT")
+	if path == "" {
+		legacySetOutput = true
+		stdPrinter.github = os.Stdout
+		stdPrinter.printNotice(statusWarning, fmt.Sprintf("$GITHUB_OUTPUT is not set; setting step outputs with the deprecated ::set-output command (--output=%s)", outputGitHub))
+		return
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		fatalf("Cannot open $GITHUB_OUTPUT for --output=%s: %v", outputGitHub, err)
+	}
+	stdPrinter.github = f
+}
+
+// Write step outputs in the $GITHUB_OUTPUT format: name=value, or a
+// heredoc for a value spanning lines. A later output of the same name
+// replaces an earlier one, so with several destinations the last wins.
+func writeGitHubOutputs(w io.Writer, outputs [][2]string) {
+	for _, o := range outputs {
+		switch {
+		case legacySetOutput:
+			fmt.Fprintf(w, "::set-output name=%s::%s\n", o[0], escapeWorkflowCommand(o[1]))
+		case strings.ContainsAny(o[1], "\r\n"):
+			delim := heredocDelimiter(o[1])
+			fmt.Fprintf(w, "%s<<%s\n%s\n%s\n", o[0], delim, o[1], delim)
+		default:
+			fmt.Fprintf(w, "%s=%s\n", o[0], o[1])
+		}
+	}
+}
+
+// A heredoc delimiter for value: random, so a value (a label, say) can't
+// end the heredoc early and set outputs of its own.
+func heredocDelimiter(value string) string {
+	for {
+		b := make([]byte, 16)
+		rand.Read(b)
+		delim := "DOCKER_RETAG_EOF_" + hex.EncodeToString(b)
+		if !strings.Contains(value, delim) {
+			return delim
+		}
+	}
+}
+
+// workflow command values escape these, so a value stays on one line
+func escapeWorkflowCommand(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// The step outputs for one successful result, named like the env
+// variables. digest is what the destination points to (the manifest list
+// with --platform). A skipped tag sets none.
+func (p *printer) printGitHubResult(r *retagResult) {
+	if r.Status == statusSkipped {
+		return
+	}
+	previous := ""
+	if r.PreviousDigest != nil {
+		previous = *r.PreviousDigest
+	}
+	writeGitHubOutputs(p.github, [][2]string{
+		{"source", r.Source},
+		{"source_digest", r.SourceDigest},
+		{"tag", r.Tag},
+		{"destination", r.Destination},
+		{"digest", writtenDigest(r.src).String()},
+		{"ref", digestRef(r.destRepo, writtenDigest(r.src))},
+		{"previous_digest", previous},
+		{"action", r.Status},
+	})
+}
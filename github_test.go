@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+func TestGitHubOutputHeredoc(t *testing.T) {
+	value := "line one\nDOCKER_RETAG_EOF\ninjected=1"
+	var b strings.Builder
+	writeGitHubOutputs(&b, [][2]string{{"diff", value}, {"action", "updated"}})
+
+	lines := strings.Split(b.String(), "\n")
+	name, delim, ok := strings.Cut(lines[0], "<<")
+	if !ok || name != "diff" || !strings.HasPrefix(delim, "DOCKER_RETAG_EOF_") {
+		t.Fatalf("first line %q, want diff<<DOCKER_RETAG_EOF_<random>", lines[0])
+	}
+	end := -1
+	for i, line := range lines[1:] {
+		if line == delim {
+			end = i + 1
+			break
+		}
+	}
+	if end < 0 {
+		t.Fatalf("heredoc never ends:\n%s", b.String())
+	}
+	if got := strings.Join(lines[1:end], "\n"); got != value {
+		t.Errorf("heredoc value %q, want %q", got, value)
+	}
+	if lines[end+1] != "action=updated" {
+		t.Errorf("line after the heredoc %q, want action=updated", lines[end+1])
+	}
+
+	var again strings.Builder
+	writeGitHubOutputs(&again, [][2]string{{"diff", value}})
+	if strings.HasPrefix(again.String(), lines[0]+"\n") {
+		t.Error("the delimiter was reused")
+	}
+}
+
+func TestEscapeWorkflowCommand(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"sha256:abc", "sha256:abc"},
+		{"100%", "100%25"},
+		{"a\nb", "a%0Ab"},
+		{"a\r\nb", "a%0D%0Ab"},
+		{"%0A", "%250A"},
+	}
+	for _, tt := range tests {
+		if got := escapeWorkflowCommand(tt.in); got != tt.want {
+			t.Errorf("escapeWorkflowCommand(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestGitHubResultSkippedSetsNothing(t *testing.T) {
+	outputFormat = outputGitHub
+	t.Cleanup(func() { outputFormat = outputText })
+
+	created, skipped := skippedResults(t)
+	out := newBufferedPrinter()
+	out.printResult(created)
+	if got := out.github.(*lockedBuffer).String(); !strings.Contains(got, "digest="+fakeDigest("build-1").String()+"\n") {
+		t.Errorf("created tag set %q, want its digest", got)
+	}
+
+	for _, r := range skipped {
+		out := newBufferedPrinter()
+		out.printResult(r)
+		if got := out.github.(*lockedBuffer).String(); got != "" {
+			t.Errorf("tag skipped for %s set %q, want nothing", *r.SkipReason, got)
+		}
+	}
+
+	out = newBufferedPrinter()
+	reportMissingSource(out, "registry.example.com/app:build-1", []string{"prod"})
+	if got := out.github.(*lockedBuffer).String(); got != "" {
+		t.Errorf("tag skipped for a missing source set %q, want nothing", got)
+	}
+}
+
+func TestGitHubResultUnderPlatformIsTheList(t *testing.T) {
+	outputFormat = outputGitHub
+	t.Cleanup(func() { outputFormat = outputText })
+
+	ref := name.MustParseReference("registry.example.com/app:multi")
+	dest := name.MustParseReference("registry.example.com/app:prod")
+	src := sourceImage{str: ref.String(), ref: ref, digest: fakeDigest("arm64"), target: fakeDigest("arm64"), list: fakeDigest("list")}
+	r := newResult(src, "prod", dest)
+	r.Status = statusCreated
+
+	out := newBufferedPrinter()
+	out.printResult(r)
+	got := out.github.(*lockedBuffer).String()
+	for _, want := range []string{
+		"digest=" + fakeDigest("list").String() + "\n",
+		"ref=registry.example.com/app@" + fakeDigest("list").String() + "\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("set %q, want %q", got, want)
+		}
+	}
+}
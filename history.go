@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// --keep-history: file that every tag move is appended to as a JSON line
+var historyFile string
+
+// one line of the --keep-history audit trail
+type historyEntry struct {
+	Timestamp      string  `json:"timestamp"`
+	Source         string  `json:"source"`
+	SourceDigest   string  `json:"source_digest"`
+	Destination    string  `json:"destination"`
+	PreviousDigest *string `json:"previous_digest"`
+	Status         string  `json:"status"`
+}
+
+// Append a record of a completed retag. The file is opened in append mode
+// and locked for the write, so concurrent --parallel workers (and other
+// docker-retag processes) never interleave lines.
+func appendHistory(res *retagResult) error {
+	entry := historyEntry{
+		Timestamp:      time.Now().UTC().Format(time.RFC3339),
+		Source:         res.Source,
+		SourceDigest:   res.src.target.String(),
+		Destination:    res.Destination,
+		PreviousDigest: res.PreviousDigest,
+		Status:         res.Status,
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("Failed to encode history entry: %v", err)
+	}
+
+	f, err := os.OpenFile(historyFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("Failed to open history file '%s': %v", historyFile, err)
+	}
+	defer f.Close()
+	if err := lockFile(f); err != nil {
+		return fmt.Errorf("Failed to lock history file '%s': %v", historyFile, err)
+	}
+	defer unlockFile(f)
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("Failed to write history file '%s': %v", historyFile, err)
+	}
+	return nil
+}
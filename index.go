@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/spf13/cobra"
+)
+
+// outcome of the index subcommand
+type indexResult struct {
+	Destination    string          `json:"destination"`
+	Digest         string          `json:"digest"`
+	MediaType      types.MediaType `json:"media_type"`
+	Manifests      []indexManifest `json:"manifests"`
+	PreviousDigest *string         `json:"previous_digest"`
+	Status         string          `json:"status"`
+}
+
+// one platform image in a created index
+type indexManifest struct {
+	Source   string `json:"source"`
+	Digest   string `json:"digest"`
+	Platform string `json:"platform"`
+}
+
+func newIndexCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "index <dst-tag> <image>...",
+		Short: "Assemble single-platform images into a multi-platform index",
+		Long: `Build a manifest list from the given single-platform images, e.g. the
+per-architecture builds of parallel CI jobs, and push it to <dst-tag>.
+Each image's platform is read from its config, and no two images may have
+the same one. The images may live in other repositories; their blobs are
+copied (or mounted) into the destination's.
+
+The result is a Docker manifest list when every image is a Docker v2
+manifest, and an OCI image index otherwise. Its digest is reported, and
+nothing is pushed if <dst-tag> already points to an identical index.`,
+		Args: cobra.MinimumNArgs(2),
+		Run:  createIndex,
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Report the index digest without pushing it")
+	return cmd
+}
+
+func createIndex(cmd *cobra.Command, args []string) {
+	parseCommonFlags()
+	rejectVariableOutput("index")
+	if platform != nil {
+		fatalf("--platform cannot be used with index: each image's platform comes from its config")
+	}
+
+	dst, err := name.NewTag(args[0], nameOptions()...)
+	if err != nil {
+		fatalf("Invalid destination tag '%s': %v", args[0], err)
+	}
+
+	ctx, cancel := setupRegistry()
+	defer cancel()
+
+	res := indexResult{Destination: dst.String(), Manifests: []indexManifest{}}
+	var adds []mutate.IndexAddendum
+	seen := map[string]string{}
+	mediaType := types.DockerManifestList
+	for _, arg := range args[1:] {
+		ref, err := name.ParseReference(arg, nameOptions()...)
+		if err != nil {
+			fatalf("Invalid image reference '%s': %v", arg, err)
+		}
+		var desc *remote.Descriptor
+		var img v1.Image
+		var cfg *v1.ConfigFile
+		err = withRetry(ctx, stdPrinter, fmt.Sprintf("Fetching '%s'", arg), func() (err error) {
+			if desc, err = registryClient.Get(ctx, ref); err != nil || desc.MediaType.IsIndex() {
+				return err
+			}
+			if img, err = desc.Image(); err != nil {
+				return err
+			}
+			cfg, err = img.ConfigFile()
+			return err
+		})
+		if err != nil {
+			exitf(registryExitCode(err, exitSourceNotFound), "Source image '%s' not found or inaccessible: %v", arg, err)
+		}
+		if desc.MediaType.IsIndex() {
+			fatalf("'%s' is already a manifest list; give its single-platform images instead", arg)
+		}
+
+		// The config is what a runtime checks, so it decides the platform.
+		p := cfg.Platform()
+		if p == nil || p.OS == "" || p.Architecture == "" {
+			fatalf("Image '%s' has no platform in its config", arg)
+		}
+		if other, ok := seen[p.String()]; ok {
+			fatalf("Images '%s' and '%s' are both %s; each platform may appear only once", other, arg, p)
+		}
+		seen[p.String()] = arg
+
+		if desc.MediaType != types.DockerManifestSchema2 {
+			mediaType = types.OCIImageIndex
+		}
+		adds = append(adds, mutate.IndexAddendum{Add: img, Descriptor: v1.Descriptor{Platform: p}})
+		res.Manifests = append(res.Manifests, indexManifest{Source: ref.String(), Digest: desc.Digest.String(), Platform: p.String()})
+	}
+
+	idx := mutate.IndexMediaType(mutate.AppendManifests(empty.Index, adds...), mediaType)
+	digest, err := idx.Digest()
+	if err != nil {
+		exitf(exitFailure, "Failed to build the index: %v", err)
+	}
+	res.Digest, res.MediaType = digest.String(), mediaType
+
+	var prev *v1.Descriptor
+	err = withRetry(ctx, stdPrinter, "Fetching destination", func() (err error) {
+		prev, err = registryClient.Head(ctx, dst)
+		return err
+	})
+	switch {
+	case err == nil:
+		d := prev.Digest.String()
+		res.PreviousDigest = &d
+	case isNotFound(err):
+		// created below
+	default:
+		exitf(registryExitCode(err, exitFailure), "Could not check whether '%s' exists: %v", dst, err)
+	}
+
+	switch {
+	case prev != nil && prev.Digest == digest:
+		res.Status = statusUnchanged
+	case dryRun && prev != nil:
+		res.Status = statusWouldUpdate
+	case dryRun:
+		res.Status = statusWouldCreate
+	default:
+		err = withRetry(ctx, stdPrinter, fmt.Sprintf("Pushing index to '%s'", dst), func() error {
+			return registryClient.Write(ctx, dst, idx, writeOptions{})
+		})
+		if err != nil && isImmutableTagError(err) {
+			exitf(exitImmutable, "Destination tag '%s' is immutable in this registry; use a new tag or disable tag immutability for the repository (%v)", dst, err)
+		}
+		if err != nil {
+			exitf(registryExitCode(err, exitWriteFailed), "Failed to push the index to '%s': %v", dst, err)
+		}
+		res.Status = statusCreated
+		if prev != nil {
+			res.Status = statusUpdated
+		}
+	}
+	stdPrinter.printIndex(res)
+}
+
+func (p *printer) printIndex(r indexResult) {
+	if quiet {
+		return
+	}
+	if outputFormat == outputJSON {
+		writeJSON(p.stdout, r)
+		return
+	}
+	what := fmt.Sprintf("index %s (%s, %d %s)", r.Digest, r.MediaType, len(r.Manifests), plural(len(r.Manifests), "platform"))
+	switch r.Status {
+	case statusUnchanged:
+		fmt.Fprintf(p.stdout, "[OK] '%s' already points to %s; nothing was pushed.\n", r.Destination, what)
+	case statusWouldCreate:
+		fmt.Fprintf(p.stdout, "[DRY-RUN] Would push %s to '%s'.\n", what, r.Destination)
+	case statusWouldUpdate:
+		fmt.Fprintf(p.stdout, "[DRY-RUN] Would push %s to '%s', replacing %s.\n", what, r.Destination, *r.PreviousDigest)
+	case statusUpdated:
+		fmt.Fprintf(p.stdout, "[OK] Pushed %s to '%s', replacing %s.\n", what, r.Destination, *r.PreviousDigest)
+	default:
+		fmt.Fprintf(p.stdout, "[OK] Pushed %s to '%s'.\n", what, r.Destination)
+	}
+	for _, m := range r.Manifests {
+		fmt.Fprintf(p.stdout, "\t%s: %s (%s)\n", m.Platform, m.Digest, m.Source)
+	}
+}
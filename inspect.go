@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/spf13/cobra"
+)
+
+// full image details printed by the inspect subcommand
+type imageInspect struct {
+	Reference    string          `json:"reference"`
+	Digest       string          `json:"digest"`
+	MediaType    types.MediaType `json:"media_type"`
+	ConfigDigest string          `json:"config_digest"`
+	Architecture string          `json:"architecture"`
+	OS           string          `json:"os"`
+	Variant      string          `json:"variant,omitempty"`
+	Created      *string         `json:"created"`
+	Author       string          `json:"author,omitempty"`
+	Config       inspectConfig   `json:"config"`
+	Layers       []inspectLayer  `json:"layers"`
+	TotalSize    int64           `json:"total_size"`
+	Index        *inspectIndex   `json:"index,omitempty"`
+}
+
+// the manifest list the inspected image was picked from
+type inspectIndex struct {
+	Digest       string               `json:"digest"`
+	MediaType    types.MediaType      `json:"media_type"`
+	Platforms    []string             `json:"platforms"`
+	Attestations []inspectAttestation `json:"attestations"`
+}
+
+// an attestation manifest (SBOM, provenance) kept alongside the platform images
+type inspectAttestation struct {
+	Digest   string `json:"digest"`
+	Subject  string `json:"subject"`
+	Platform string `json:"platform,omitempty"`
+}
+
+type inspectConfig struct {
+	User       string            `json:"user,omitempty"`
+	Env        []string          `json:"env,omitempty"`
+	Entrypoint []string          `json:"entrypoint,omitempty"`
+	Cmd        []string          `json:"cmd,omitempty"`
+	WorkingDir string            `json:"working_dir,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+}
+
+type inspectLayer struct {
+	Digest    string          `json:"digest"`
+	MediaType types.MediaType `json:"media_type"`
+	Size      int64           `json:"size"`
+}
+
+func newInspectCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "inspect <image>",
+		Short: "Print full image details (digest, config, layers) as JSON",
+		Long: `Print the digest, media type, config and layers of a remote image as a
+JSON document, without retagging anything. For a manifest list, the
+image for --platform (or the default platform) is inspected, and "index"
+lists the platforms it holds and, separately, its attestation manifests
+(the SBOM and provenance BuildKit attaches, by the digest of the image
+they describe).
+
+"digest" is the manifest digest, the one repo@sha256:... references pin;
+"config_digest" is the digest of the image config, the image ID that
+docker images shows. The two never match.`,
+		Args: cobra.ExactArgs(1),
+		Run:  inspectImage,
+	}
+}
+
+func inspectImage(cmd *cobra.Command, args []string) {
+	parseCommonFlags()
+	rejectVariableOutput("inspect")
+
+	ref, err := name.ParseReference(args[0], nameOptions()...)
+	if err != nil {
+		fatalf("Invalid image reference '%s': %v", args[0], err)
+	}
+
+	ctx, cancel := setupRegistry()
+	defer cancel()
+
+	var desc *remote.Descriptor
+	var img v1.Image
+	err = withRetry(ctx, stdPrinter, "Fetching image", func() (err error) {
+		if desc, err = registryClient.Get(ctx, ref); err != nil {
+			return err
+		}
+		img, err = desc.Image()
+		return err
+	})
+	if err != nil {
+		exitf(registryExitCode(err, exitSourceNotFound), "Image '%s' not found or inaccessible: %v", args[0], err)
+	}
+
+	details, err := describeImage(ref, img)
+	if err == nil && desc.MediaType.IsIndex() {
+		details.Index, err = describeIndex(desc)
+	}
+	if err != nil {
+		exitf(registryExitCode(err, exitFailure), "Failed to read image '%s': %v", args[0], err)
+	}
+
+	out, err := json.MarshalIndent(details, "", "  ")
+	if err != nil {
+		exitf(exitFailure, "Failed to encode image details: %v", err)
+	}
+	fmt.Println(string(out))
+}
+
+// Collect the manifest, config and layer details of an image.
+func describeImage(ref name.Reference, img v1.Image) (*imageInspect, error) {
+	digest, err := img.Digest()
+	if err != nil {
+		return nil, err
+	}
+	mediaType, err := img.MediaType()
+	if err != nil {
+		return nil, err
+	}
+	configDigest, err := img.ConfigName()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, err
+	}
+
+	details := &imageInspect{
+		Reference:    ref.String(),
+		Digest:       digest.String(),
+		MediaType:    mediaType,
+		ConfigDigest: configDigest.String(),
+		Architecture: cfg.Architecture,
+		OS:           cfg.OS,
+		Variant:      cfg.Variant,
+		Created:      formatRFC3339(cfg.Created.Time),
+		Author:       cfg.Author,
+		Config: inspectConfig{
+			User:       cfg.Config.User,
+			Env:        cfg.Config.Env,
+			Entrypoint: cfg.Config.Entrypoint,
+			Cmd:        cfg.Config.Cmd,
+			WorkingDir: cfg.Config.WorkingDir,
+			Labels:     cfg.Config.Labels,
+		},
+		Layers: []inspectLayer{},
+	}
+	// Sizes come from the manifest descriptors, so no layer blobs are fetched.
+	for _, layer := range manifest.Layers {
+		details.Layers = append(details.Layers, inspectLayer{Digest: layer.Digest.String(), MediaType: layer.MediaType, Size: layer.Size})
+		details.TotalSize += layer.Size
+	}
+	return details, nil
+}
+
+// Sort the children of a manifest list into platform images and attestations.
+func describeIndex(desc *remote.Descriptor) (*inspectIndex, error) {
+	idx, err := desc.ImageIndex()
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := idx.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	details := &inspectIndex{Digest: desc.Digest.String(), MediaType: desc.MediaType, Platforms: []string{}, Attestations: []inspectAttestation{}}
+	for _, child := range manifest.Manifests {
+		switch {
+		case isAttestation(child):
+			att := inspectAttestation{Digest: child.Digest.String(), Subject: child.Annotations[annotationReferenceDigest]}
+			if p := attestedPlatform(manifest, child); p != nil {
+				att.Platform = p.String()
+			}
+			details.Attestations = append(details.Attestations, att)
+		case child.Platform != nil:
+			details.Platforms = append(details.Platforms, child.Platform.String())
+		}
+	}
+	return details, nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// --details for the list subcommand
+	listDetails bool
+
+	listCreatedAfterStr  string
+	listCreatedBeforeStr string
+	// parsed from --created-after and --created-before; zero means unbounded
+	listCreatedAfter  time.Time
+	listCreatedBefore time.Time
+)
+
+// one tag in list output
+type tagEntry struct {
+	Tag     string  `json:"tag"`
+	Digest  *string `json:"digest,omitempty"`
+	Created *string `json:"created,omitempty"`
+	Error   string  `json:"error,omitempty"`
+
+	digest  v1.Hash
+	created time.Time
+}
+
+func newListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list <repository>",
+		Short: "List the tags in a repository",
+		Long: `List the tags in a remote repository, one per line.
+
+With --details, each tag's digest and creation time are fetched as well
+(one extra request per tag). --created-after and --created-before fetch the
+same details and only print tags created within the window, e.g. to find
+stale tags to clean up; tags with an unknown creation time are left out.
+The details of up to --parallel tags are fetched at once. With
+--output=json, one JSON object is written per tag.`,
+		Args: cobra.ExactArgs(1),
+		Run:  listTags,
+	}
+	cmd.Flags().BoolVar(&listDetails, "details", false, "Also show each tag's digest and creation time")
+	cmd.Flags().StringVar(&listCreatedAfterStr, "created-after", "", "Only list tags created after this time: a duration ago (e.g., 720h), RFC3339 time or date")
+	cmd.Flags().StringVar(&listCreatedBeforeStr, "created-before", "", "Only list tags created before this time: a duration ago (e.g., 720h), RFC3339 time or date")
+	cmd.Flags().IntVar(&parallel, "parallel", runtime.NumCPU(), "Number of tags to fetch details for concurrently")
+	return cmd
+}
+
+func listTags(cmd *cobra.Command, args []string) {
+	parseCommonFlags()
+	rejectVariableOutput("list")
+
+	repo, err := parseRepository(args[0])
+	if err != nil {
+		fatalf("Invalid repository '%s': %v", args[0], err)
+	}
+	if parallel < 1 {
+		fatalf("Invalid --parallel %d: must be at least 1", parallel)
+	}
+	if listCreatedAfterStr != "" {
+		if listCreatedAfter, err = parsePointInTime(listCreatedAfterStr); err != nil {
+			fatalf("Invalid --created-after '%s': %v", listCreatedAfterStr, err)
+		}
+	}
+	if listCreatedBeforeStr != "" {
+		if listCreatedBefore, err = parsePointInTime(listCreatedBeforeStr); err != nil {
+			fatalf("Invalid --created-before '%s': %v", listCreatedBeforeStr, err)
+		}
+	}
+	filtered := !listCreatedAfter.IsZero() || !listCreatedBefore.IsZero()
+
+	ctx, cancel := setupRegistry()
+	defer cancel()
+
+	var tags []string
+	err = withRetry(ctx, stdPrinter, "Listing tags", func() (err error) {
+		tags, err = registryClient.List(ctx, repo)
+		return err
+	})
+	if err != nil {
+		exitf(registryExitCode(err, exitSourceNotFound), "Failed to list tags in '%s': %v", repo, err)
+	}
+
+	entries := make([]tagEntry, len(tags))
+	for i, tag := range tags {
+		entries[i] = tagEntry{Tag: tag}
+	}
+	if listDetails || filtered {
+		fetchAllDetails(ctx, repo, entries)
+	}
+	for _, entry := range entries {
+		// A failed fetch is still shown, so it isn't mistaken for a tag
+		// outside the window.
+		if filtered && entry.Error == "" && !entry.createdWithin() {
+			continue
+		}
+		stdPrinter.printTag(entry)
+	}
+}
+
+// Fetch the details of every entry, up to --parallel at once.
+func fetchAllDetails(ctx context.Context, repo name.Repository, entries []tagEntry) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, parallel)
+	for i := range entries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			entries[i].fetchDetails(ctx, repo)
+		}()
+	}
+	wg.Wait()
+}
+
+// Report whether the tag was created within --created-after/--created-before.
+// An unknown creation time never is.
+func (e *tagEntry) createdWithin() bool {
+	if e.created.IsZero() {
+		return false
+	}
+	if !listCreatedAfter.IsZero() && !e.created.After(listCreatedAfter) {
+		return false
+	}
+	if !listCreatedBefore.IsZero() && !e.created.Before(listCreatedBefore) {
+		return false
+	}
+	return true
+}
+
+// Accept a bare repository or any reference within it (e.g., repo:tag).
+func parseRepository(s string) (name.Repository, error) {
+	if repo, err := name.NewRepository(s, nameOptions()...); err == nil {
+		return repo, nil
+	}
+	ref, err := name.ParseReference(s, nameOptions()...)
+	if err != nil {
+		return name.Repository{}, err
+	}
+	return ref.Context(), nil
+}
+
+// Fill in the digest and creation time; failures are recorded on the entry
+// so one unreadable tag doesn't abort the listing.
+func (e *tagEntry) fetchDetails(ctx context.Context, repo name.Repository) {
+	err := withRetry(ctx, stdPrinter, fmt.Sprintf("Fetching tag '%s'", e.Tag), func() (err error) {
+		details, _, err := fetchImage(ctx, repo.Tag(e.Tag))
+		e.digest, e.created = details.digest, details.created
+		return err
+	})
+	if err != nil {
+		e.Error = err.Error()
+		return
+	}
+	d := e.digest.String()
+	e.Digest = &d
+	e.Created = formatRFC3339(e.created)
+}
+
+func (p *printer) printTag(e tagEntry) {
+	if quiet {
+		return
+	}
+	if outputFormat == outputJSON {
+		writeJSON(p.stdout, e)
+		return
+	}
+	switch {
+	case !listDetails:
+		fmt.Fprintln(p.stdout, e.Tag)
+	case e.Error != "":
+		fmt.Fprintf(p.stdout, "%s\t[FAIL] %s\n", e.Tag, e.Error)
+	default:
+		fmt.Fprintf(p.stdout, "%s\t%s\t%s\n", e.Tag, formatDigest(e.digest), formatTime(e.created))
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// A source is local when it looks like a path (contains a separator or is a
+// tarball) and exists on disk. Relative paths in the current directory need
+// a "./" prefix so they can't be mistaken for Docker Hub images.
+func isLocalSource(s string) bool {
+	if !strings.ContainsRune(s, filepath.Separator) && !strings.ContainsRune(s, '/') && !isTarball(s) {
+		return false
+	}
+	_, err := os.Stat(s)
+	return err == nil
+}
+
+func isTarball(s string) bool {
+	return strings.HasSuffix(s, ".tar") || strings.HasSuffix(s, ".tar.gz") || strings.HasSuffix(s, ".tgz")
+}
+
+// Opener for a docker-save tarball that may be gzipped, as .tar.gz and .tgz
+// files are; the gzip magic bytes decide, not the suffix. The tarball
+// package reads the file more than once, so each call opens it afresh.
+func openTarball(path string) tarball.Opener {
+	return func() (io.ReadCloser, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		r := bufio.NewReader(f)
+		if magic, _ := r.Peek(2); !bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+			return readCloser{Reader: r, Closer: f}, nil
+		}
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return readCloser{Reader: zr, Closer: f}, nil
+	}
+}
+
+// a reader whose Close closes the file underneath it
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// Load an OCI layout directory or a docker-save tarball. An OCI layout must
+// hold exactly one top-level manifest (an image or an index); with
+// --platform, an index is narrowed to the matching image.
+func loadLocalSource(path string) (remote.Taggable, imageDetails, bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, imageDetails{}, false, err
+	}
+
+	if !info.IsDir() {
+		img, err := tarball.Image(openTarball(path), nil)
+		if err != nil {
+			return nil, imageDetails{}, false, fmt.Errorf("Failed to load tarball: %v", err)
+		}
+		details, err := getImageDetails(img)
+		if err != nil {
+			return nil, imageDetails{}, false, err
+		}
+		return img, details, false, nil
+	}
+
+	lp, err := layout.FromPath(path)
+	if err != nil {
+		return nil, imageDetails{}, false, fmt.Errorf("Not an OCI layout: %v", err)
+	}
+	root, err := lp.ImageIndex()
+	if err != nil {
+		return nil, imageDetails{}, false, err
+	}
+	manifest, err := root.IndexManifest()
+	if err != nil {
+		return nil, imageDetails{}, false, err
+	}
+	if len(manifest.Manifests) != 1 {
+		return nil, imageDetails{}, false, fmt.Errorf("OCI layout must contain exactly one manifest, found %d", len(manifest.Manifests))
+	}
+
+	desc := manifest.Manifests[0]
+	if !desc.MediaType.IsIndex() {
+		img, err := root.Image(desc.Digest)
+		if err != nil {
+			return nil, imageDetails{}, false, err
+		}
+		details, err := getImageDetails(img)
+		if err != nil {
+			return nil, imageDetails{}, false, err
+		}
+		return img, details, false, nil
+	}
+
+	idx, err := root.ImageIndex(desc.Digest)
+	if err != nil {
+		return nil, imageDetails{}, false, err
+	}
+	if platform == nil {
+		return idx, imageDetails{digest: desc.Digest}, true, nil
+	}
+
+	children, err := idx.IndexManifest()
+	if err != nil {
+		return nil, imageDetails{}, false, err
+	}
+	var available []string
+	for _, child := range children.Manifests {
+		if child.Platform == nil {
+			continue
+		}
+		available = append(available, child.Platform.String())
+		if child.Platform.Satisfies(*platform) {
+			img, err := idx.Image(child.Digest)
+			if err != nil {
+				return nil, imageDetails{}, false, err
+			}
+			details, err := getImageDetails(img)
+			if err != nil {
+				return nil, imageDetails{}, false, err
+			}
+			return img, details, false, nil
+		}
+	}
+	return nil, imageDetails{}, false, fmt.Errorf("Platform '%s' not found. Available platforms: %s", platform, strings.Join(available, ", "))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+func TestLoadLocalTarball(t *testing.T) {
+	img, err := random.Image(256, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "img.tar")
+	if err := tarball.WriteToFile(plain, name.MustParseReference("example.com/app:v1"), img); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, suffix := range []string{".tar.gz", ".tgz"} {
+		f, err := os.Create(filepath.Join(dir, "img"+suffix))
+		if err != nil {
+			t.Fatal(err)
+		}
+		zw := gzip.NewWriter(f)
+		if _, err := zw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+
+	for _, file := range []string{"img.tar", "img.tar.gz", "img.tgz"} {
+		path := filepath.Join(dir, file)
+		if !isLocalSource(path) {
+			t.Errorf("%s: not recognised as a local source", file)
+			continue
+		}
+		_, details, isIndex, err := loadLocalSource(path)
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+			continue
+		}
+		if isIndex || details.digest != want {
+			t.Errorf("%s: loaded %s (index %v), want image %s", file, details.digest, isIndex, want)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	ggcrregistry "github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Register the flags, so their variables hold the defaults a plain run sees.
+func TestMain(m *testing.M) {
+	newRootCmd()
+	os.Exit(m.Run())
+}
+
+// Start an in-memory registry for the test and return its host. Loopback
+// hosts are spoken to over plain HTTP, so no TLS setup is needed.
+func newTestRegistry(t *testing.T) string {
+	t.Helper()
+	s := httptest.NewServer(ggcrregistry.New(ggcrregistry.Logger(log.New(io.Discard, "", 0))))
+	t.Cleanup(s.Close)
+	return strings.TrimPrefix(s.URL, "http://")
+}
+
+// Push a random single-layer image to ref and return it.
+func pushImage(t *testing.T, ref string) v1.Image {
+	t.Helper()
+	img, err := random.Image(256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := name.NewTag(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(r, img); err != nil {
+		t.Fatalf("pushing %s: %v", ref, err)
+	}
+	return img
+}
+
+func digestOf(t *testing.T, img v1.Image) v1.Hash {
+	t.Helper()
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// the digest ref currently points to in the registry
+func headDigest(t *testing.T, ref string) v1.Hash {
+	t.Helper()
+	r, err := name.ParseReference(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := remote.Head(r)
+	if err != nil {
+		t.Fatalf("HEAD %s: %v", ref, err)
+	}
+	return desc.Digest
+}
+
+// Resolve source and point tag at it, as one line of a run would.
+func retagForTest(t *testing.T, source, tag string) *retagResult {
+	t.Helper()
+	ctx := context.Background()
+	out := newBufferedPrinter()
+	src, err := resolveSource(ctx, out, source)
+	if err != nil {
+		t.Fatalf("resolving %s: %v", source, err)
+	}
+	res, err := retagOne(ctx, out, src, tag)
+	if err != nil {
+		t.Fatalf("retagging %s to %s: %v", source, tag, err)
+	}
+	return res
+}
+
+func TestRetagStatuses(t *testing.T) {
+	host := newTestRegistry(t)
+	build1 := digestOf(t, pushImage(t, host+"/app:build-1"))
+	build2 := digestOf(t, pushImage(t, host+"/app:build-2"))
+
+	steps := []struct {
+		source     string
+		wantStatus string
+		wantDigest v1.Hash
+		wantPrev   string
+	}{
+		{host + "/app:build-1", statusCreated, build1, ""},
+		{host + "/app:build-1", statusUnchanged, build1, build1.String()},
+		{host + "/app:build-2", statusUpdated, build2, build1.String()},
+	}
+	for _, s := range steps {
+		res := retagForTest(t, s.source, "prod")
+		if res.Status != s.wantStatus {
+			t.Errorf("%s -> prod: status %q, want %q", s.source, res.Status, s.wantStatus)
+		}
+		prev := ""
+		if res.PreviousDigest != nil {
+			prev = *res.PreviousDigest
+		}
+		if prev != s.wantPrev {
+			t.Errorf("%s -> prod: previous digest %q, want %q", s.source, prev, s.wantPrev)
+		}
+		if got := headDigest(t, host+"/app:prod"); got != s.wantDigest {
+			t.Errorf("%s -> prod: tag points to %s, want %s", s.source, got, s.wantDigest)
+		}
+	}
+}
+
+func TestRetagAcrossRepositories(t *testing.T) {
+	host := newTestRegistry(t)
+	want := digestOf(t, pushImage(t, host+"/dev/app:build-1"))
+
+	res := retagForTest(t, host+"/dev/app:build-1", host+"/prod/app:release")
+	if res.Status != statusCreated {
+		t.Errorf("status %q, want %q", res.Status, statusCreated)
+	}
+	if got := headDigest(t, host+"/prod/app:release"); got != want {
+		t.Errorf("tag points to %s, want %s", got, want)
+	}
+}
+
+func TestRetagDryRunWritesNothing(t *testing.T) {
+	host := newTestRegistry(t)
+	pushImage(t, host+"/app:build-1")
+	dryRun = true
+	t.Cleanup(func() { dryRun = false })
+
+	res := retagForTest(t, host+"/app:build-1", "prod")
+	if res.Status != statusWouldCreate {
+		t.Errorf("status %q, want %q", res.Status, statusWouldCreate)
+	}
+	r, _ := name.NewTag(host + "/app:prod")
+	if _, err := remote.Head(r); err == nil {
+		t.Error("dry run created the tag")
+	}
+}
+
+// registry whose manifest fetches of one repository are forbidden
+type forbiddenRegistry struct {
+	remoteRegistry
+	repo string
+}
+
+func (f forbiddenRegistry) Get(ctx context.Context, ref name.Reference) (*remote.Descriptor, error) {
+	if ref.Context().RepositoryStr() == f.repo {
+		return nil, &transport.Error{StatusCode: http.StatusForbidden, Errors: []transport.Diagnostic{{Code: transport.DeniedErrorCode}}}
+	}
+	return f.remoteRegistry.Get(ctx, ref)
+}
+
+func TestRetagFailsWhenDestinationCantBeChecked(t *testing.T) {
+	host := newTestRegistry(t)
+	pushImage(t, host+"/dev/app:build-1")
+	useRegistry(t, forbiddenRegistry{repo: "prod/app"})
+
+	ctx := context.Background()
+	src, err := resolveSource(ctx, newBufferedPrinter(), host+"/dev/app:build-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = retagOne(ctx, newBufferedPrinter(), src, host+"/prod/app:release")
+	if err == nil || !strings.Contains(err.Error(), "Could not check whether tag") {
+		t.Fatalf("got error %v, want a failed existence check", err)
+	}
+	if code := exitCode(err); code != exitAuth {
+		t.Errorf("exit code %d, want %d", code, exitAuth)
+	}
+	r, _ := name.NewTag(host + "/prod/app:release")
+	if _, err := remote.Head(r); err == nil {
+		t.Error("the tag was written anyway")
+	}
+}
+
+// Push a two-platform index, linux/amd64 and linux/arm64, to ref and return
+// its digest.
+func pushIndex(t *testing.T, ref string) v1.Hash {
+	t.Helper()
+	var adds []mutate.IndexAddendum
+	for _, arch := range []string{"amd64", "arm64"} {
+		img, err := random.Image(256, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		adds = append(adds, mutate.IndexAddendum{Add: img, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: arch}}})
+	}
+	idx := mutate.AppendManifests(empty.Index, adds...)
+	r, err := name.NewTag(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(r, idx); err != nil {
+		t.Fatalf("pushing %s: %v", ref, err)
+	}
+	return headDigest(t, ref)
+}
+
+func TestPlatformSourceIsPinnedToTheList(t *testing.T) {
+	host := newTestRegistry(t)
+	want := pushIndex(t, host+"/app:multi")
+	platform = &v1.Platform{OS: "linux", Architecture: "arm64"}
+	t.Cleanup(func() { platform = nil })
+
+	src, err := resolveSource(context.Background(), newBufferedPrinter(), host+"/app:multi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pinnedSource(src); got != host+"/app@"+want.String() {
+		t.Errorf("pinned source %s, want the list %s", got, want)
+	}
+	// Moving the source tag afterwards doesn't change what is copied.
+	pushIndex(t, host+"/app:multi")
+	if _, err := retagOne(context.Background(), newBufferedPrinter(), src, "prod"); err != nil {
+		t.Fatal(err)
+	}
+	if got := headDigest(t, host+"/app:prod"); got != want {
+		t.Errorf("tag points to %s, want %s", got, want)
+	}
+}
+
+func TestPlatformPrintsTheListPinned(t *testing.T) {
+	host := newTestRegistry(t)
+	list := pushIndex(t, host+"/app:multi")
+	platform = &v1.Platform{OS: "linux", Architecture: "arm64"}
+	printPinned = true
+	t.Cleanup(func() { platform, printPinned = nil, false })
+
+	out := newBufferedPrinter()
+	if failed, _ := promote(context.Background(), out, host+"/app:multi", []string{"prod"}); len(failed) > 0 {
+		t.Fatalf("failed tags %q", failed)
+	}
+	lines := strings.Split(strings.TrimSpace(out.stdout.(*lockedBuffer).String()), "\n")
+	if got, want := lines[len(lines)-1], host+"/app@"+list.String(); got != want {
+		t.Errorf("pinned %s, want the list %s", got, want)
+	}
+}
+
+// In-process registry of one repository's tags, for driving code that
+// only lists and resolves tags. Other methods panic through the nil
+// embedded interface.
+type fakeRegistry struct {
+	registry
+	tags  map[string]v1.Hash
+	calls map[string]int // method name to count
+}
+
+func newFakeRegistry(tags map[string]v1.Hash) *fakeRegistry {
+	return &fakeRegistry{tags: tags, calls: map[string]int{}}
+}
+
+func (f *fakeRegistry) List(ctx context.Context, repo name.Repository) ([]string, error) {
+	f.calls["List"]++
+	tags := make([]string, 0, len(f.tags))
+	for tag := range f.tags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+func (f *fakeRegistry) Head(ctx context.Context, ref name.Reference) (*v1.Descriptor, error) {
+	f.calls["Head"]++
+	d, ok := f.tags[ref.Identifier()]
+	if !ok {
+		return nil, &transport.Error{StatusCode: http.StatusNotFound, Errors: []transport.Diagnostic{{Code: transport.ManifestUnknownErrorCode}}}
+	}
+	return &v1.Descriptor{Digest: d, MediaType: types.DockerManifestSchema2}, nil
+}
+
+// a digest made from s, for fixtures that need distinct ones
+func fakeDigest(s string) v1.Hash {
+	h, _, _ := v1.SHA256(strings.NewReader(s))
+	return h
+}
+
+// Swap registryClient for r until the test ends.
+func useRegistry(t *testing.T, r registry) {
+	t.Helper()
+	registryClient = r
+	t.Cleanup(func() { registryClient = remoteRegistry{} })
+}
+
+func TestParsePointInTime(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Time // zero for an error
+	}{
+		{"24h", time.Now().Add(-24 * time.Hour)},
+		{"2024-06-01T12:00:00Z", time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)},
+		{"2024-06-01T12:00:00+02:00", time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)},
+		{"2024-06-01", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{"yesterday", time.Time{}},
+		{"2024-13-01", time.Time{}},
+	}
+	for _, tt := range tests {
+		got, err := parsePointInTime(tt.in)
+		switch {
+		case tt.want.IsZero():
+			if err == nil {
+				t.Errorf("parsePointInTime(%q) = %s, want an error", tt.in, got)
+			}
+		case err != nil:
+			t.Errorf("parsePointInTime(%q): %v", tt.in, err)
+		case got.Sub(tt.want).Abs() > time.Minute:
+			t.Errorf("parsePointInTime(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+var (
+	matchPattern   string
+	renameTemplate string
+
+	// compiled from --match, anchored to the whole tag; nil when not set
+	matchRegexp *regexp.Regexp
+)
+
+// Compile --match, which must match a whole tag, and check --rename.
+func parseMatch() error {
+	if matchPattern == "" {
+		if renameTemplate != "" {
+			return fmt.Errorf("--rename requires --match")
+		}
+		return nil
+	}
+	if renameTemplate == "" {
+		return fmt.Errorf("--match requires --rename")
+	}
+	if _, err := regexp.Compile(matchPattern); err != nil {
+		return fmt.Errorf("Invalid --match '%s': %v", matchPattern, err)
+	}
+	matchRegexp = regexp.MustCompile("^(?:" + matchPattern + ")$")
+	return nil
+}
+
+// Promote every tag in repo that matches --match to the tag computed by
+// --rename, through the batch machinery so --parallel and the summary apply.
+// Each mapping is reported; tags that don't match are ignored.
+func runMatch(ctx context.Context, repo name.Repository) int {
+	var tags []string
+	err := withRetry(ctx, stdPrinter, "Listing tags", func() (err error) {
+		tags, err = registryClient.List(ctx, repo)
+		return err
+	})
+	if err != nil {
+		exitf(registryExitCode(err, exitSourceNotFound), "Failed to list tags in '%s': %v", repo, err)
+	}
+
+	var lines strings.Builder
+	for _, tag := range tags {
+		m := matchRegexp.FindStringSubmatchIndex(tag)
+		if m == nil {
+			continue
+		}
+		dest := string(matchRegexp.ExpandString(nil, renameTemplate, tag, m))
+		if dest == "" || strings.ContainsAny(dest, " \t") {
+			stdPrinter.printError(exitUsage, tag, repo.Tag(tag).String(), fmt.Sprintf("--rename maps tag '%s' to invalid tag '%s'", tag, dest))
+			return exitUsage
+		}
+		stdPrinter.printNotice(statusMapping, fmt.Sprintf("%s -> %s", tag, dest))
+		fmt.Fprintf(&lines, "%s %s\n", repo.Tag(tag), dest)
+	}
+	if lines.Len() == 0 {
+		exitf(exitSourceNotFound, "No tags in '%s' match --match '%s'", repo, matchPattern)
+	}
+	return runBatch(ctx, strings.NewReader(lines.String()))
+}
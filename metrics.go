@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// --metrics-file: Prometheus textfile the run's metrics are written to on exit
+var metricsFile string
+
+// --metrics-file status of a destination tag that failed
+const tagFailed = "failed"
+
+// when the process started, for the run duration
+var runStart = time.Now()
+
+// what --metrics-file reports, updated by every worker
+var runMetrics struct {
+	requests atomic.Int64
+	retries  atomic.Int64
+	sent     atomic.Int64
+	received atomic.Int64
+
+	mu   sync.Mutex
+	tags []tagMetric
+}
+
+// one destination tag of the run
+type tagMetric struct {
+	destination string
+	status      string // the result status, or tagFailed
+	seconds     float64
+}
+
+// Record how one destination tag went, for --metrics-file.
+func recordTag(destination, status string, start time.Time) {
+	if metricsFile == "" {
+		return
+	}
+	runMetrics.mu.Lock()
+	defer runMetrics.mu.Unlock()
+	runMetrics.tags = append(runMetrics.tags, tagMetric{destination: destination, status: status, seconds: time.Since(start).Seconds()})
+}
+
+// Write the metrics in the Prometheus text format, for node_exporter's
+// textfile collector. Every value describes this run only, so they are all
+// gauges. The file is replaced by a rename, so the collector never reads a
+// partial one. A failure is reported but doesn't change the exit code.
+func writeMetrics(code int) {
+	if metricsFile == "" {
+		return
+	}
+	runMetrics.mu.Lock()
+	tags := append([]tagMetric(nil), runMetrics.tags...)
+	runMetrics.mu.Unlock()
+
+	var b strings.Builder
+	gauge := func(name, help string) {
+		fmt.Fprintf(&b, "# HELP docker_retag_%s %s\n# TYPE docker_retag_%s gauge\n", name, help, name)
+	}
+	gauge("run_duration_seconds", "Time the docker-retag run took.")
+	fmt.Fprintf(&b, "docker_retag_run_duration_seconds %g\n", time.Since(runStart).Seconds())
+	gauge("last_run_timestamp_seconds", "When the run finished, as a Unix time.")
+	fmt.Fprintf(&b, "docker_retag_last_run_timestamp_seconds %d\n", time.Now().Unix())
+	gauge("exit_code", "Exit code of the run; 0 means every tag succeeded.")
+	fmt.Fprintf(&b, "docker_retag_exit_code %d\n", code)
+
+	counts := map[string]int{}
+	for _, t := range tags {
+		counts[t.status]++
+	}
+	statuses := make([]string, 0, len(counts))
+	for status := range counts {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	gauge("tags", "Destination tags by result status.")
+	for _, status := range statuses {
+		fmt.Fprintf(&b, "docker_retag_tags{status=\"%s\"} %d\n", escapeLabel(status), counts[status])
+	}
+	// A destination named more than once in a batch is one series, of the
+	// time spent on it in total.
+	type series struct{ destination, status string }
+	seconds := map[series]float64{}
+	for _, t := range tags {
+		seconds[series{t.destination, t.status}] += t.seconds
+	}
+	keys := make([]series, 0, len(seconds))
+	for k := range seconds {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].destination != keys[j].destination {
+			return keys[i].destination < keys[j].destination
+		}
+		return keys[i].status < keys[j].status
+	})
+	gauge("tag_duration_seconds", "Time spent on each destination tag, summed over repeats of it.")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "docker_retag_tag_duration_seconds{destination=\"%s\",status=\"%s\"} %g\n", escapeLabel(k.destination), escapeLabel(k.status), seconds[k])
+	}
+
+	gauge("registry_requests", "Registry HTTP requests made, retries included.")
+	fmt.Fprintf(&b, "docker_retag_registry_requests %d\n", runMetrics.requests.Load())
+	gauge("retries", "Registry operations retried after a transient failure.")
+	fmt.Fprintf(&b, "docker_retag_retries %d\n", runMetrics.retries.Load())
+	gauge("transferred_bytes", "Bytes sent to and received from registries.")
+	fmt.Fprintf(&b, "docker_retag_transferred_bytes{direction=\"sent\"} %d\n", runMetrics.sent.Load())
+	fmt.Fprintf(&b, "docker_retag_transferred_bytes{direction=\"received\"} %d\n", runMetrics.received.Load())
+
+	tmp := metricsFile + ".tmp"
+	err := os.WriteFile(tmp, []byte(b.String()), 0o644)
+	if err == nil {
+		err = os.Rename(tmp, metricsFile)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		stdPrinter.printNotice(statusWarning, fmt.Sprintf("Failed to write metrics file '%s': %v", metricsFile, err))
+	}
+}
+
+// label values may hold any character but these three
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// counts the requests and body bytes going through it for --metrics-file
+type metricsTransport struct {
+	inner http.RoundTripper
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	runMetrics.requests.Add(1)
+	if req.Body != nil && req.Body != http.NoBody {
+		req = req.Clone(req.Context())
+		req.Body = &countingReader{ReadCloser: req.Body, n: &runMetrics.sent}
+	}
+	resp, err := t.inner.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	resp.Body = &countingReader{ReadCloser: resp.Body, n: &runMetrics.received}
+	return resp, nil
+}
+
+type countingReader struct {
+	io.ReadCloser
+	n *atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n.Add(int64(n))
+	return n, err
+}
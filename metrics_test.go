@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMetricsSumRepeatedDestinations(t *testing.T) {
+	metricsFile = filepath.Join(t.TempDir(), "retag.prom")
+	runMetrics.tags = []tagMetric{
+		{destination: "registry.example/app:prod", status: statusUpdated, seconds: 1.5},
+		{destination: "registry.example/app:prod", status: statusUpdated, seconds: 2},
+		{destination: "registry.example/app:prod", status: tagFailed, seconds: 0.25},
+	}
+	t.Cleanup(func() { metricsFile, runMetrics.tags = "", nil })
+
+	writeMetrics(exitOK)
+	data, err := os.ReadFile(metricsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var series []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "docker_retag_tag_duration_seconds{") {
+			series = append(series, line)
+		}
+	}
+	want := []string{
+		`docker_retag_tag_duration_seconds{destination="registry.example/app:prod",status="failed"} 0.25`,
+		`docker_retag_tag_duration_seconds{destination="registry.example/app:prod",status="updated"} 3.5`,
+	}
+	if strings.Join(series, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got series\n%s\nwant\n%s", strings.Join(series, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestMetricsNameUnresolvedSourceDestinations(t *testing.T) {
+	metricsFile = "unused"
+	t.Cleanup(func() { metricsFile, runMetrics.tags = "", nil })
+
+	host := newTestRegistry(t)
+	promote(t.Context(), newBufferedPrinter(), host+"/app:missing", []string{"prod"})
+	if got := runMetrics.tags[len(runMetrics.tags)-1].destination; got != host+"/app:prod" {
+		t.Errorf("recorded destination %q, want %q", got, host+"/app:prod")
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/spf13/cobra"
+)
+
+// one tag of a mirror run and, once run, its buffered output and outcome
+type mirrorJob struct {
+	tag    string
+	out    *printer
+	status string
+	code   int
+	done   chan struct{}
+	ran    bool
+}
+
+// --repository-prefix for the mirror subcommand, as old=new
+var repositoryPrefix string
+
+// a source repository and the repository its tags are mirrored to
+type mirrorPair struct {
+	src, dst name.Repository
+}
+
+// tag outcomes across a mirror run
+type mirrorCounts struct {
+	copied, skipped, failed, notRun int
+}
+
+func newMirrorCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mirror <src-repo> <dst-repo> | mirror --repository-prefix old=new <src-repo>...",
+		Short: "Copy every tag of a repository to another repository",
+		Long: `Copy every tag in <src-repo> to the same tag in <dst-repo>, e.g. for
+disaster-recovery replication. Each tag goes through the same idempotency
+check as a single retag, so tags that already point to the same image are
+skipped. Up to --parallel tags are copied at once; each tag's output is
+printed as a block in listing order, followed by a summary of copied,
+skipped and failed tags.
+
+With --repository-prefix old=new, every argument is a source repository and
+its destination is its name with the old prefix replaced, keeping the rest
+of the path: olddomain/team=newdomain/team mirrors olddomain/team/app to
+newdomain/team/app. Each mapping is reported before its tags are copied.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if repositoryPrefix != "" {
+				return cobra.MinimumNArgs(1)(cmd, args)
+			}
+			return cobra.ExactArgs(2)(cmd, args)
+		},
+		Run: mirrorRepository,
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Report what would be copied without making changes")
+	cmd.Flags().IntVar(&parallel, "parallel", runtime.NumCPU(), "Number of tags to copy concurrently")
+	cmd.Flags().BoolVar(&cacheDestinations, "cache-destinations", false, "List the destination repository once and check tags against it, instead of fetching every destination tag")
+	cmd.Flags().BoolVar(&abortOnFailure, "abort-on-first-failure", false, "Start no further tag copies once one has failed; copies already running finish")
+	cmd.Flags().BoolVar(&continueOnFailure, "continue", false, "Copy every tag despite failures and report them in the summary (the default)")
+	cmd.Flags().StringVar(&repositoryPrefix, "repository-prefix", "", "Derive each destination from its source repository by replacing a prefix, as old=new (e.g., old.io/team=new.io/team)")
+	return cmd
+}
+
+func mirrorRepository(cmd *cobra.Command, args []string) {
+	parseCommonFlags()
+	rejectVariableOutput("mirror")
+
+	if parallel < 1 {
+		fatalf("Invalid --parallel %d: must be at least 1", parallel)
+	}
+	checkFailureMode()
+	pairs, err := mirrorPairs(args)
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	ctx, cancel := setupRegistry()
+	defer cancel()
+
+	var counts mirrorCounts
+	code := exitOK
+	d := newDispatcher()
+	for _, pair := range pairs {
+		if abortOnFailure && code != exitOK {
+			stdPrinter.printNotice(statusNote, fmt.Sprintf("Not mirroring '%s' after an earlier failure (--abort-on-first-failure)", pair.src))
+			continue
+		}
+		if repositoryPrefix != "" {
+			stdPrinter.printNotice(statusMapping, fmt.Sprintf("%s -> %s", pair.src, pair.dst))
+		}
+		if c := mirrorTags(ctx, d, pair, &counts); code == exitOK {
+			code = c
+		}
+	}
+
+	verb := "copied"
+	if dryRun {
+		verb = "to copy"
+	}
+	stdPrinter.printNotice(statusSummary, fmt.Sprintf("%d %s, %d skipped (already identical), %d failed%s", counts.copied, verb, counts.skipped, counts.failed, notRunNote(counts.notRun, "tag")))
+	exit(code)
+}
+
+// The repositories to mirror: the two arguments, or with --repository-prefix
+// each argument and its rewritten name.
+func mirrorPairs(args []string) ([]mirrorPair, error) {
+	if repositoryPrefix == "" {
+		srcRepo, err := name.NewRepository(args[0], nameOptions()...)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid source repository '%s': %v", args[0], err)
+		}
+		dstRepo, err := name.NewRepository(args[1], nameOptions()...)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid destination repository '%s': %v", args[1], err)
+		}
+		if srcRepo == dstRepo {
+			return nil, fmt.Errorf("Source and destination repository are the same: '%s'", srcRepo)
+		}
+		return []mirrorPair{{srcRepo, dstRepo}}, nil
+	}
+
+	oldPrefix, newPrefix, ok := strings.Cut(repositoryPrefix, "=")
+	oldPrefix, newPrefix = strings.TrimSuffix(oldPrefix, "/"), strings.TrimSuffix(newPrefix, "/")
+	if !ok || oldPrefix == "" || newPrefix == "" {
+		return nil, fmt.Errorf("Invalid --repository-prefix '%s': must be old=new", repositoryPrefix)
+	}
+	pairs := make([]mirrorPair, 0, len(args))
+	for _, arg := range args {
+		srcRepo, err := name.NewRepository(arg, nameOptions()...)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid source repository '%s': %v", arg, err)
+		}
+		// Match whole path segments, against the name as given or in full
+		// (index.docker.io/library/...), so old.io/team doesn't match old.io/teamx.
+		var rest string
+		matched := false
+		for _, n := range []string{strings.TrimSuffix(arg, "/"), srcRepo.Name()} {
+			if n == oldPrefix || strings.HasPrefix(n, oldPrefix+"/") {
+				rest, matched = strings.TrimPrefix(n, oldPrefix), true
+				break
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("Source repository '%s' does not start with the --repository-prefix '%s'", arg, oldPrefix)
+		}
+		dst := newPrefix + rest
+		dstRepo, err := name.NewRepository(dst, nameOptions()...)
+		if err != nil {
+			return nil, fmt.Errorf("--repository-prefix maps '%s' to '%s', which is not a valid repository: %v", arg, dst, err)
+		}
+		if srcRepo == dstRepo {
+			return nil, fmt.Errorf("--repository-prefix maps '%s' to itself", arg)
+		}
+		pairs = append(pairs, mirrorPair{srcRepo, dstRepo})
+	}
+	return pairs, nil
+}
+
+// Copy every tag of one repository through d, adding the outcomes to
+// counts. Returns the exit code of the first failure.
+func mirrorTags(ctx context.Context, d *dispatcher, pair mirrorPair, counts *mirrorCounts) int {
+	var tags []string
+	err := withRetry(ctx, stdPrinter, "Listing tags", func() (err error) {
+		tags, err = registryClient.List(ctx, pair.src)
+		return err
+	})
+	if err != nil {
+		code := interruptedCode(registryExitCode(err, exitSourceNotFound))
+		stdPrinter.printError(code, "", pair.src.String(), fmt.Sprintf("Failed to list tags in '%s': %v", pair.src, err))
+		return code
+	}
+
+	jobs := make([]*mirrorJob, len(tags))
+	for i, tag := range tags {
+		jobs[i] = &mirrorJob{tag: tag, out: newBufferedPrinter(), done: make(chan struct{})}
+	}
+	go func() {
+		for _, job := range jobs {
+			d.run(job.done, func() bool {
+				job.ran = true
+				job.run(ctx, pair.src, pair.dst)
+				return job.code == exitOK
+			})
+		}
+	}()
+
+	code := exitOK
+	for _, job := range jobs {
+		<-job.done
+		job.out.flushTo(stdPrinter)
+		switch {
+		case !job.ran:
+			counts.notRun++
+		case job.code != exitOK:
+			counts.failed++
+			if code == exitOK {
+				code = job.code
+			}
+		case job.status == statusUnchanged:
+			counts.skipped++
+		default:
+			counts.copied++
+		}
+	}
+	return code
+}
+
+// Copy one tag, resolving it first so the copy is pinned to a digest.
+func (j *mirrorJob) run(ctx context.Context, srcRepo, dstRepo name.Repository) {
+	ctx = withPrinter(ctx, j.out)
+	dest := dstRepo.Tag(j.tag).String()
+	src, err := resolveSource(ctx, j.out, srcRepo.Tag(j.tag).String())
+	if err == nil {
+		var res *retagResult
+		if res, err = retagOne(ctx, j.out, src, dest); err == nil {
+			j.out.printResult(res)
+			j.status = res.Status
+			return
+		}
+	}
+	j.code = exitCode(err)
+	j.out.printError(j.code, j.tag, dest, err.Error())
+}
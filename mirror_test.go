@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestMirrorPairsPrefix(t *testing.T) {
+	tests := []struct {
+		prefix, arg string
+		want        string // the destination; empty for an error
+	}{
+		{"old.io/team=new.io/team", "old.io/team/app", "new.io/team/app"},
+		{"old.io/team/=new.io/mirror/", "old.io/team/svc/api", "new.io/mirror/svc/api"},
+		{"old.io/team=new.io/team", "old.io/team", "new.io/team"},
+		// Whole path segments only.
+		{"old.io/team=new.io/team", "old.io/teamx/app", ""},
+		// Docker Hub names match in full, too.
+		{"index.docker.io/library=mirror.io/hub", "ubuntu", "mirror.io/hub/ubuntu"},
+		{"old.io/team=old.io/team", "old.io/team/app", ""},
+		{"old.io/team=new.io/Team", "old.io/team/app", ""},
+		{"old.io/team", "old.io/team/app", ""},
+		{"=new.io/team", "old.io/team/app", ""},
+	}
+	t.Cleanup(func() { repositoryPrefix = "" })
+	for _, tt := range tests {
+		repositoryPrefix = tt.prefix
+		pairs, err := mirrorPairs([]string{tt.arg})
+		switch {
+		case tt.want == "":
+			if err == nil {
+				t.Errorf("%s with %s: mapped to %s, want an error", tt.arg, tt.prefix, pairs[0].dst)
+			}
+		case err != nil:
+			t.Errorf("%s with %s: %v", tt.arg, tt.prefix, err)
+		case pairs[0].dst.String() != tt.want:
+			t.Errorf("%s with %s: mapped to %s, want %s", tt.arg, tt.prefix, pairs[0].dst, tt.want)
+		}
+	}
+}
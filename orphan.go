@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// --check-orphan: look for other tags still pointing to a released digest
+var checkOrphan bool
+
+// Record that the tag no longer points to its previous digest. With
+// --check-orphan, every other tag in the repository is resolved (one HEAD
+// request each) to tell whether the digest is still tagged or may now be
+// garbage collected. A failed check is only a warning. With --platform the
+// previous digest is a platform's image, which other tags hold only through
+// their manifest lists, so the check is skipped.
+func releasePrevious(ctx context.Context, out *printer, res *retagResult, dest name.Tag) {
+	d := res.prevDigest.String()
+	res.ReleasedDigest = &d
+	if !checkOrphan {
+		return
+	}
+	if platform != nil {
+		out.printNotice(statusWarning, fmt.Sprintf("Not checking whether %s is still tagged: with --platform it is one platform's image, which tags reach through their manifest lists (--check-orphan)", digestRef(dest.Context(), res.prevDigest)))
+		return
+	}
+	tags, err := tagsPointingTo(ctx, out, dest.Context(), res.prevDigest, dest.TagStr())
+	if err != nil {
+		out.printNotice(statusWarning, fmt.Sprintf("Could not check whether %s is still tagged: %v", digestRef(dest.Context(), res.prevDigest), err))
+		return
+	}
+	res.ReleasedTaggedBy = tags
+}
+
+// The tags in repo, other than skip, that point directly to digest. Tags
+// are resolved up to --parallel at once; the result is never nil.
+func tagsPointingTo(ctx context.Context, out *printer, repo name.Repository, digest v1.Hash, skip string) ([]string, error) {
+	var tags []string
+	err := withRetry(ctx, out, "Listing tags", func() (err error) {
+		tags, err = registryClient.List(ctx, repo)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]bool, len(tags))
+	errs := make([]error, len(tags))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, max(parallel, 1))
+	for i, tag := range tags {
+		if tag == skip {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			errs[i] = withRetry(ctx, out, fmt.Sprintf("Fetching tag '%s'", tag), func() error {
+				desc, err := registryClient.Head(ctx, repo.Tag(tag))
+				if err == nil {
+					matches[i] = desc.Digest == digest
+				}
+				return err
+			})
+		}()
+	}
+	wg.Wait()
+
+	found := []string{}
+	for i, tag := range tags {
+		if errs[i] != nil && !isNotFound(errs[i]) {
+			return nil, fmt.Errorf("tag '%s': %w", tag, errs[i])
+		}
+		if matches[i] {
+			found = append(found, tag)
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+func TestTagsPointingTo(t *testing.T) {
+	released, other := fakeDigest("released"), fakeDigest("other")
+	fake := newFakeRegistry(map[string]v1.Hash{
+		"prod":    other,
+		"staging": released,
+		"v1":      released,
+		"v2":      other,
+	})
+	useRegistry(t, fake)
+	repo, _ := name.NewRepository("registry.example/app")
+
+	got, err := tagsPointingTo(context.Background(), newBufferedPrinter(), repo, released, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"staging", "v1"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if fake.calls["List"] != 1 || fake.calls["Head"] != 3 {
+		t.Errorf("made %v calls, want 1 List and a Head per other tag", fake.calls)
+	}
+}
+
+func TestReleasePreviousSkipsPlatformImages(t *testing.T) {
+	fake := newFakeRegistry(map[string]v1.Hash{"staging": fakeDigest("list")})
+	useRegistry(t, fake)
+	checkOrphan, platform = true, &v1.Platform{OS: "linux", Architecture: "arm64"}
+	t.Cleanup(func() { checkOrphan, platform = false, nil })
+
+	dest, _ := name.NewTag("registry.example/app:prod")
+	res := &retagResult{prevDigest: fakeDigest("child")}
+	releasePrevious(context.Background(), newBufferedPrinter(), res, dest)
+	if res.ReleasedTaggedBy != nil || len(fake.calls) != 0 {
+		t.Errorf("checked other tags (%v, calls %v); want the check skipped", res.ReleasedTaggedBy, fake.calls)
+	}
+	if res.ReleasedDigest == nil || *res.ReleasedDigest != fakeDigest("child").String() {
+		t.Errorf("released digest %v, want it still reported", res.ReleasedDigest)
+	}
+}
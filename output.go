@@ -0,0 +1,485 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// Supported values for --output
+const (
+	outputText = "text"
+	outputJSON = "json"
+	outputEnv  = "env" // shell variables for eval; see env.go
+	// GitHub Actions step outputs, next to the text output; see github.go
+	outputGitHub = "github"
+)
+
+// Result statuses, also used as the "status" field in JSON output
+const (
+	statusUnchanged    = "unchanged"
+	statusCreated      = "created"
+	statusUpdated      = "updated"
+	statusRewritten    = "rewritten"
+	statusSkipped      = "skipped"
+	statusMatched      = "matched"
+	statusWritten      = "written"
+	statusWouldCreate  = "would-create"
+	statusWouldUpdate  = "would-update"
+	statusWouldRewrite = "would-rewrite"
+	statusWouldWrite   = "would-write"
+	statusDeleted      = "deleted"
+	statusReachable    = "reachable"
+	statusError        = "error"
+	statusRetry        = "retry"
+	statusWaiting      = "waiting"
+	statusProgress     = "progress"
+	statusWarning      = "warning"
+	statusSummary      = "summary"
+	statusInterrupted  = "interrupted"
+	statusNote         = "note"
+	statusMapping      = "mapping"
+	statusNormalized   = "normalized"
+)
+
+// Reasons for a skipped tag, also used as the "skip_reason" field in JSON output
+const (
+	skipIfNewer       = "if-newer"
+	skipLabelFilter   = "label-filter"
+	skipMissingSource = "missing-source"
+)
+
+// outcome of retagging a single destination tag
+type retagResult struct {
+	Source           string           `json:"source"`
+	SourceDigest     string           `json:"source_digest"`
+	SourceCreated    *string          `json:"source_created"`
+	SourceIndex      bool             `json:"source_is_index"`
+	SourceMediaType  string           `json:"source_media_type"`
+	SourceRef        *string          `json:"source_ref"`
+	AnnotatedDigest  *string          `json:"annotated_digest"`
+	SourceLayers     *int             `json:"source_layers"`
+	SourceSize       *int64           `json:"source_size"`
+	SourceAttests    *int             `json:"source_attestations"`
+	SourceConfig     *string          `json:"source_config_digest"`
+	Tag              string           `json:"tag"`
+	Destination      string           `json:"destination"`
+	PreviousDigest   *string          `json:"previous_digest"`
+	PreviousRef      *string          `json:"previous_ref"`
+	ActionTaken      bool             `json:"action_taken"`
+	DryRun           bool             `json:"dry_run"`
+	Verified         bool             `json:"verified"`
+	Reconciled       bool             `json:"reconciled"`
+	CopiedSignatures []string         `json:"copied_signatures"`
+	CopiedReferrers  []string         `json:"copied_referrers"`
+	BlobsMounted     *int             `json:"blobs_mounted"`
+	BlobsUploaded    *int             `json:"blobs_uploaded"`
+	BlobsExisting    *int             `json:"blobs_existing"`
+	ReleasedDigest   *string          `json:"released_digest"`
+	ReleasedTaggedBy []string         `json:"released_tagged_by"`
+	Diff             []string         `json:"diff"`
+	MetadataDiff     []string         `json:"metadata_diff"`
+	Layers           *layerComparison `json:"layer_comparison"`
+	SkipReason       *string          `json:"skip_reason"`
+	Status           string           `json:"status"`
+
+	// kept for text output
+	src         sourceImage
+	destRepo    name.Repository
+	hasPrev     bool
+	prevDigest  v1.Hash
+	prevCreated time.Time
+}
+
+// error object written to stderr in JSON mode. error repeats message for
+// consumers of the original format.
+type errorResult struct {
+	Status    string `json:"status"`
+	Level     string `json:"level"`
+	Code      int    `json:"code"`
+	Tag       string `json:"tag,omitempty"`
+	Reference string `json:"reference,omitempty"`
+	Message   string `json:"message"`
+	Error     string `json:"error"`
+}
+
+func newResult(src sourceImage, tag string, dest name.Reference) *retagResult {
+	return &retagResult{
+		Source:          src.str,
+		SourceDigest:    src.digest.String(),
+		SourceCreated:   formatRFC3339(src.created),
+		SourceIndex:     src.index,
+		SourceMediaType: string(src.mediaType),
+		SourceRef:       sourceDigestRef(src),
+		AnnotatedDigest: annotatedDigest(src),
+		SourceLayers:    sizeLayers(src.size),
+		SourceSize:      sizeBytes(src.size),
+		SourceAttests:   sizeAttestations(src.size),
+		SourceConfig:    configDigest(src),
+		Tag:             tag,
+		Destination:     dest.String(),
+		DryRun:          dryRun,
+		src:             src,
+		destRepo:        dest.Context(),
+	}
+}
+
+// record the digest and creation time the tag pointed to before the retag
+func (r *retagResult) setPrevious(digest v1.Hash, created time.Time) {
+	r.hasPrev = true
+	r.prevDigest = digest
+	r.prevCreated = created
+	d := digest.String()
+	r.PreviousDigest = &d
+	ref := digestRef(r.destRepo, digest)
+	r.PreviousRef = &ref
+}
+
+// mark the tag as skipped for the given reason
+func (r *retagResult) skip(reason string) {
+	r.Status = statusSkipped
+	r.SkipReason = &reason
+}
+
+// record the blob transfers of a write to another repository
+func (r *retagResult) setBlobStats(s *blobStats) {
+	r.BlobsMounted = &s.mounted
+	r.BlobsUploaded = &s.uploaded
+	r.BlobsExisting = &s.existing
+}
+
+// --quiet suppresses everything except errors
+var quiet bool
+
+// --no-warnings suppresses advisory warnings only
+var noWarnings bool
+
+// destination for results, errors and notices; batch workers each get a
+// buffered printer so their output can be flushed without interleaving
+type printer struct {
+	stdout io.Writer
+	stderr io.Writer
+	// step outputs of --output=github
+	github io.Writer
+}
+
+var stdPrinter = &printer{stdout: os.Stdout, stderr: os.Stderr, github: io.Discard}
+
+// printer that collects output in memory until flushed
+func newBufferedPrinter() *printer {
+	return &printer{stdout: &lockedBuffer{}, stderr: &lockedBuffer{}, github: &lockedBuffer{}}
+}
+
+// copy buffered output to another printer
+func (p *printer) flushTo(dst *printer) {
+	if b, ok := p.stdout.(*lockedBuffer); ok {
+		_, _ = b.WriteTo(dst.stdout)
+	}
+	if b, ok := p.stderr.(*lockedBuffer); ok {
+		_, _ = b.WriteTo(dst.stderr)
+	}
+	if b, ok := p.github.(*lockedBuffer); ok {
+		_, _ = b.WriteTo(dst.github)
+	}
+}
+
+// a buffered printer's output; besides the line itself, progress reports and
+// go-containerregistry's workers (through retry429Transport) write to it
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) WriteTo(w io.Writer) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.WriteTo(w)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+type printerKey struct{}
+
+// ctx carrying out, for output from below the registry calls, such as
+// retry429Transport's, to reach the line's own printer
+func withPrinter(ctx context.Context, out *printer) context.Context {
+	return context.WithValue(ctx, printerKey{}, out)
+}
+
+// the printer ctx carries, or stdPrinter
+func printerFrom(ctx context.Context) *printer {
+	if out, ok := ctx.Value(printerKey{}).(*printer); ok {
+		return out
+	}
+	return stdPrinter
+}
+
+func (p *printer) printResult(r *retagResult) {
+	// The variables are what a script evals, so --quiet doesn't drop them.
+	if outputFormat == outputEnv {
+		p.printEnvResult(r)
+		return
+	}
+	// The step outputs are set even with --quiet; the text is for the log.
+	if outputFormat == outputGitHub {
+		p.printGitHubResult(r)
+	}
+	if quiet {
+		return
+	}
+	if outputFormat == outputJSON {
+		writeJSON(p.stdout, r)
+		return
+	}
+
+	sourceName := r.src.str + "@" + r.src.digest.String()
+	if r.SourceRef != nil {
+		sourceName = *r.SourceRef
+	}
+	source := fmt.Sprintf("\tSource: %s %s", formatTime(r.src.created), sourceName)
+	if r.src.index {
+		source += " (manifest list)"
+	}
+	// Make clear which immutable image a mutable source tag stood for.
+	if tag, ok := r.src.ref.(name.Tag); ok {
+		source += fmt.Sprintf("\n\tResolved: :%s -> %s", tag.TagStr(), r.src.digest)
+	}
+	if showConfigDigest {
+		source += fmt.Sprintf("\n\tManifest digest: %s", r.src.digest)
+		switch {
+		case r.src.config != (v1.Hash{}) && r.src.index:
+			source += fmt.Sprintf("\n\tConfig digest (image ID): %s (default platform's image)", r.src.config)
+		case r.src.config != (v1.Hash{}):
+			source += fmt.Sprintf("\n\tConfig digest (image ID): %s", r.src.config)
+		default:
+			source += "\n\tConfig digest (image ID): unknown"
+		}
+	}
+	if len(annotations) > 0 {
+		source += fmt.Sprintf("\n\tAnnotated: %s", digestRef(r.destRepo, r.src.target))
+	}
+	target := fmt.Sprintf("\tTarget: %s %s", formatTime(r.prevCreated), digestRef(r.destRepo, r.prevDigest))
+	sizeNote := fmt.Sprintf(" (%s)", r.src.mediaType)
+	if r.src.size != nil {
+		sizeNote = fmt.Sprintf(" (%s, %s)", r.src.mediaType, r.src.size)
+	}
+	switch r.Status {
+	case statusUnchanged:
+		if r.DryRun {
+			fmt.Fprintf(p.stdout, "[DRY-RUN] No change: tag '%s' already points to the correct image.\n%s\n", r.Tag, source)
+		} else {
+			fmt.Fprintf(p.stdout, "[OK] Tag '%s' already points to the correct image.\n%s\n", r.Tag, source)
+		}
+	case statusMatched:
+		fmt.Fprintf(p.stdout, "[OK] Source resolves to '%s'; nothing was tagged.\n%s\n", r.Destination, source)
+	case statusSkipped:
+		if *r.SkipReason == skipMissingSource {
+			fmt.Fprintf(p.stdout, "[SKIP] Source image '%s' not present; tag '%s' left unchanged (--tolerate-missing-source).\n", r.Source, r.Tag)
+			break
+		}
+		if *r.SkipReason == skipLabelFilter {
+			fmt.Fprintf(p.stdout, "[SKIP] Source labels don't match %s; tag '%s' left unchanged (--label-filter).\n%s\n", formatLabelFilters(), r.Tag, source)
+			break
+		}
+		fmt.Fprintf(p.stdout, "[SKIP] Source not newer than destination; tag '%s' left unchanged (--if-newer).\n%s\n%s\n", r.Tag, source, target)
+	case statusWouldUpdate:
+		fmt.Fprintf(p.stdout, "[DRY-RUN] Would point tag '%s' from %s to %s.\n%s\n%s\n",
+			r.Tag, formatDigest(r.prevDigest), formatDigest(r.src.target), source, target)
+	case statusWouldRewrite:
+		fmt.Fprintf(p.stdout, "[DRY-RUN] Would rewrite tag '%s' (--force); it already points to the correct image.\n%s\n", r.Tag, source)
+	case statusWouldWrite:
+		fmt.Fprintf(p.stdout, "[DRY-RUN] Would point tag '%s' to %s (destination not checked).\n%s\n", r.Tag, formatDigest(r.src.target), source)
+	case statusWouldCreate:
+		fmt.Fprintf(p.stdout, "[DRY-RUN] Would create tag '%s' pointing to %s.\n%s\n", r.Tag, formatDigest(r.src.target), source)
+	case statusUpdated:
+		fmt.Fprintf(p.stdout, "[OK] Successfully pointed tag '%s' to new image%s.\n%s\n%s\n", r.Tag, sizeNote, source, target)
+	case statusRewritten:
+		fmt.Fprintf(p.stdout, "[OK] Rewrote tag '%s'%s (--force); content was already identical.\n%s\n", r.Tag, sizeNote, source)
+	case statusWritten:
+		fmt.Fprintf(p.stdout, "[OK] Pointed tag '%s' to the source image%s (destination not checked).\n%s\n", r.Tag, sizeNote, source)
+	case statusCreated:
+		fmt.Fprintf(p.stdout, "[OK] Successfully pointed tag '%s' to new image%s.\n%s\n", r.Tag, sizeNote, source)
+	}
+	if r.ReleasedDigest != nil {
+		released := digestRef(r.destRepo, r.prevDigest)
+		switch {
+		case r.ReleasedTaggedBy == nil:
+			fmt.Fprintf(p.stdout, "\tReleased: %s (other tags not checked; see --check-orphan)\n", released)
+		case len(r.ReleasedTaggedBy) == 0:
+			fmt.Fprintf(p.stdout, "\tReleased: %s is no longer tagged and may be garbage collected\n", released)
+		default:
+			fmt.Fprintf(p.stdout, "\tReleased: %s (still tagged: %s)\n", released, strings.Join(r.ReleasedTaggedBy, ", "))
+		}
+	}
+	if r.Diff != nil {
+		if len(r.Diff) == 0 {
+			fmt.Fprintln(p.stdout, "\tDiff: no config or layer changes")
+		} else {
+			fmt.Fprintf(p.stdout, "\tDiff (destination -> source):\n\t\t%s\n", strings.Join(r.Diff, "\n\t\t"))
+		}
+	}
+	if r.Layers != nil {
+		fmt.Fprintf(p.stdout, "\tLayers (destination -> source): %s\n", r.Layers)
+		for _, d := range r.Layers.DestinationOnly {
+			fmt.Fprintf(p.stdout, "\t\t- %s\n", d)
+		}
+		for _, d := range r.Layers.SourceOnly {
+			fmt.Fprintf(p.stdout, "\t\t+ %s\n", d)
+		}
+	}
+	if r.MetadataDiff != nil {
+		if len(r.MetadataDiff) == 0 {
+			fmt.Fprintln(p.stdout, "\tMetadata: no annotation or label changes")
+		} else {
+			fmt.Fprintf(p.stdout, "\tMetadata (destination -> source):\n\t\t%s\n", strings.Join(r.MetadataDiff, "\n\t\t"))
+		}
+	}
+	if r.Verified {
+		fmt.Fprintln(p.stdout, "\tVerified: destination now resolves to the expected digest")
+	}
+	if r.Reconciled {
+		fmt.Fprintln(p.stdout, "\tReconciled: source still resolves to the promoted digest")
+	}
+	if r.BlobsMounted != nil && r.ActionTaken {
+		fmt.Fprintf(p.stdout, "\tBlobs: %d mounted, %d uploaded, %d already present\n", *r.BlobsMounted, *r.BlobsUploaded, *r.BlobsExisting)
+	}
+	if r.CopiedSignatures != nil {
+		fmt.Fprintf(p.stdout, "\tSignatures: %s\n", formatSignatures(r.CopiedSignatures))
+	}
+	if r.CopiedReferrers != nil {
+		fmt.Fprintf(p.stdout, "\tReferrers: %d copied\n", len(r.CopiedReferrers))
+	}
+}
+
+// Write a pinned reference as a bare line. It is the point of --print-pinned,
+// so --quiet doesn't suppress it.
+func (p *printer) printPinned(ref string) {
+	fmt.Fprintln(p.stdout, ref)
+}
+
+// Report a failure on stderr. code is the exit code the failure maps to; tag
+// and ref (the offending reference) may be empty for errors not tied to one.
+func (p *printer) printError(code int, tag, ref, msg string) {
+	if outputFormat == outputJSON {
+		writeJSON(p.stderr, errorResult{Status: statusError, Level: "error", Code: code, Tag: tag, Reference: ref, Message: msg, Error: msg})
+		return
+	}
+	fmt.Fprintf(p.stderr, "[FAIL] Error: %s\n", msg)
+}
+
+// progress message on stderr that is neither a result nor a failure
+type noticeResult struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// report a non-fatal event such as a retry on stderr
+func (p *printer) printNotice(status, msg string) {
+	if quiet || (noWarnings && status == statusWarning) {
+		return
+	}
+	if outputFormat == outputJSON {
+		writeJSON(p.stderr, noticeResult{Status: status, Message: msg})
+		return
+	}
+	fmt.Fprintf(p.stderr, "[%s] %s\n", strings.ToUpper(status), msg)
+}
+
+// report invalid arguments and exit
+func fatalf(format string, args ...any) {
+	exitf(exitUsage, format, args...)
+}
+
+// report a fatal failure and exit with code
+func exitf(code int, format string, args ...any) {
+	code = interruptedCode(code)
+	stdPrinter.printError(code, "", "", fmt.Sprintf(format, args...))
+	exit(code)
+}
+
+func writeJSON(w io.Writer, v any) {
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "[FAIL] Error: Failed to encode JSON output: %v\n", err)
+	}
+}
+
+// config digest of the source image, or nil when unknown
+func configDigest(src sourceImage) *string {
+	if src.config == (v1.Hash{}) {
+		return nil
+	}
+	d := src.config.String()
+	return &d
+}
+
+// digest of the annotated manifest, or nil when --annotation is not used
+func annotatedDigest(src sourceImage) *string {
+	if len(annotations) == 0 {
+		return nil
+	}
+	d := src.target.String()
+	return &d
+}
+
+// pullable reference to the source by digest, or nil for a local source
+func sourceDigestRef(src sourceImage) *string {
+	if src.ref == nil {
+		return nil
+	}
+	ref := digestRef(src.ref.Context(), src.digest)
+	return &ref
+}
+
+func sizeLayers(s *imageSize) *int {
+	if s == nil {
+		return nil
+	}
+	return &s.layers
+}
+
+func sizeBytes(s *imageSize) *int64 {
+	if s == nil {
+		return nil
+	}
+	return &s.bytes
+}
+
+func sizeAttestations(s *imageSize) *int {
+	if s == nil {
+		return nil
+	}
+	return &s.attestations
+}
+
+// canonical, pullable repo@digest reference
+func digestRef(repo name.Repository, digest v1.Hash) string {
+	return repo.Digest(digest.String()).String()
+}
+
+// RFC3339 timestamp, or nil when the time is unknown
+func formatRFC3339(t time.Time) *string {
+	if t.IsZero() {
+		return nil
+	}
+	s := t.UTC().Format(time.RFC3339)
+	return &s
+}
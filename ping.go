@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/spf13/cobra"
+)
+
+// --require-push for the ping subcommand
+var requirePush bool
+
+// outcome of the ping subcommand
+type pingResult struct {
+	Registry   string `json:"registry"`
+	Repository string `json:"repository"`
+	AuthScheme string `json:"auth_scheme"` // "bearer", "basic" or "anonymous"
+	Push       bool   `json:"push"`
+	PushError  string `json:"push_error,omitempty"`
+	Status     string `json:"status"`
+}
+
+func newPingCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ping <repository>",
+		Short: "Check that a registry is reachable and the credentials work",
+		Long: `Preflight check for a promotion: ping the registry's /v2/ endpoint, perform
+the auth handshake for the repository, and check whether push is granted by
+starting (and immediately cancelling) a blob upload. No manifests are read
+and nothing is written.
+
+A registry that can't be reached or that rejects the credentials fails with
+the network or auth exit code. Missing push permission is only reported,
+unless --require-push is given.`,
+		Args: cobra.ExactArgs(1),
+		Run:  pingRegistry,
+	}
+	cmd.Flags().BoolVar(&requirePush, "require-push", false, "Fail (exit 4) unless push to the repository is granted")
+	return cmd
+}
+
+func pingRegistry(cmd *cobra.Command, args []string) {
+	parseCommonFlags()
+	rejectVariableOutput("ping")
+
+	repo, err := parseRepository(args[0])
+	if err != nil {
+		fatalf("Invalid repository '%s': %v", args[0], err)
+	}
+
+	ctx, cancel := setupRegistry()
+	defer cancel()
+
+	// The ping and handshakes bypass remote, so the User-Agent is set here.
+	t := transport.NewUserAgent(registryTransport, userAgent)
+	reg := repo.Registry
+	var challenge *transport.Challenge
+	err = withRetry(ctx, stdPrinter, fmt.Sprintf("Pinging '%s'", reg), func() (err error) {
+		challenge, err = transport.Ping(ctx, reg, t)
+		return err
+	})
+	if err != nil {
+		exitf(registryExitCode(err, exitNetwork), "Registry '%s' is not reachable: %v", reg, err)
+	}
+
+	auth := registryAuth
+	if auth == nil {
+		if auth, err = registryKeychain.Resolve(reg); err != nil {
+			exitf(exitAuth, "Failed to resolve credentials for '%s': %v", reg, err)
+		}
+	}
+	if _, err := transport.NewWithContext(ctx, reg, auth, t, []string{repo.Scope(transport.PullScope)}); err != nil {
+		exitf(registryExitCode(err, exitAuth), "Registry '%s' rejected the credentials: %v", reg, err)
+	}
+
+	res := pingResult{Registry: reg.String(), Repository: repo.String(), AuthScheme: challenge.Scheme, Status: statusReachable}
+	if res.AuthScheme == "" {
+		res.AuthScheme = "anonymous"
+	}
+	if err := checkPush(ctx, t, repo, auth, challenge); err != nil {
+		res.PushError = err.Error()
+	} else {
+		res.Push = true
+	}
+	stdPrinter.printPing(res)
+
+	if requirePush && !res.Push {
+		exitf(exitAuth, "Push to '%s' is not granted (--require-push)", repo)
+	}
+}
+
+// Start a blob upload to see whether push is authorized, then cancel it.
+// The token handshake alone isn't enough: some registries (Docker Hub) hand
+// out a pull-only token for a push scope instead of refusing.
+func checkPush(ctx context.Context, t http.RoundTripper, repo name.Repository, auth authn.Authenticator, challenge *transport.Challenge) error {
+	pushTransport, err := transport.NewWithContext(ctx, repo.Registry, auth, t, []string{repo.Scope(transport.PushScope)})
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Transport: pushTransport}
+	scheme := "https"
+	if challenge.Insecure {
+		scheme = "http"
+	}
+	uploads := fmt.Sprintf("%s://%s/v2/%s/blobs/uploads/", scheme, repo.RegistryStr(), repo.RepositoryStr())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uploads, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := transport.CheckError(resp, http.StatusAccepted); err != nil {
+		return err
+	}
+
+	// The upload session may reserve resources, so give it back; a failure
+	// here doesn't change the answer.
+	if loc, err := resp.Location(); err == nil {
+		if req, err := http.NewRequestWithContext(ctx, http.MethodDelete, loc.String(), nil); err == nil {
+			if resp, err := client.Do(req); err == nil {
+				resp.Body.Close()
+			}
+		}
+	}
+	return nil
+}
+
+func (p *printer) printPing(r pingResult) {
+	if quiet {
+		return
+	}
+	if outputFormat == outputJSON {
+		writeJSON(p.stdout, r)
+		return
+	}
+	fmt.Fprintf(p.stdout, "[OK] Registry '%s' is reachable and accepted the credentials (auth: %s).\n", r.Registry, r.AuthScheme)
+	if r.Push {
+		fmt.Fprintf(p.stdout, "\tPush: granted for %s\n", r.Repository)
+	} else {
+		fmt.Fprintf(p.stdout, "\tPush: not granted for %s: %s\n", r.Repository, r.PushError)
+	}
+}
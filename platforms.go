@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// --platform value that promotes every platform to its own suffixed tag
+const platformAll = "all"
+
+// Supported values for --source-manifest-list-policy
+const (
+	listPolicyIndex  = "index"
+	listPolicyCoerce = "coerce"
+	listPolicyError  = "error"
+)
+
+// Supported values for --expected-platform-match
+const (
+	platformMatchAny = "any"
+	platformMatchAll = "all"
+)
+
+// the platform go-containerregistry picks from a manifest list without --platform
+var defaultPlatform = v1.Platform{OS: "linux", Architecture: "amd64"}
+
+var (
+	// set by --platform all; platform stays nil so the whole index is promoted
+	allPlatforms bool
+	// --per-platform-suffix, appended to each tag for a platform's image
+	platformSuffix string
+	// --source-manifest-list-policy: whether a manifest list source is
+	// promoted whole, narrowed to one image or refused
+	sourceListPolicy string
+	// --expected-os and --expected-arch: the platform the source must be
+	expectedOS, expectedArch string
+	// --expected-platform-match: whether any or every platform of a manifest
+	// list must be the expected one
+	expectedMatch string
+)
+
+// Apply --source-manifest-list-policy to a resolved remote source. With
+// --platform only that platform's image was resolved, so the reference
+// itself is looked at to tell whether it is a manifest list.
+func applyListPolicy(ctx context.Context, out *printer, src *sourceImage) error {
+	if sourceListPolicy == listPolicyIndex {
+		return nil
+	}
+	isIndex, listDigest, listType := src.index, src.digest, src.mediaType
+	if platform != nil {
+		var desc *v1.Descriptor
+		err := withRetry(ctx, out, "Fetching source manifest", func() (err error) {
+			desc, err = registryClient.Head(ctx, readRef(src.ref))
+			return err
+		})
+		if err != nil {
+			return withExitCode(registryExitCode(err, exitSourceNotFound), fmt.Errorf("Source image '%s' not found or inaccessible: %w", src.str, err))
+		}
+		isIndex, listDigest, listType = desc.MediaType.IsIndex(), desc.Digest, desc.MediaType
+	}
+	if !isIndex {
+		return nil
+	}
+	if sourceListPolicy == listPolicyError {
+		return manifestListRefused(src.str, listType)
+	}
+
+	// Coerce: the image the metadata came from is also what gets promoted.
+	want := defaultPlatform
+	if platform == nil {
+		var img v1.Image
+		err := withRetry(ctx, out, fmt.Sprintf("Fetching %s image", want), func() (err error) {
+			img, err = registryClient.Image(ctx, readRef(src.ref.Context().Digest(src.digest.String())))
+			return err
+		})
+		if err != nil {
+			return withExitCode(registryExitCode(err, exitSourceNotFound), fmt.Errorf("Failed to fetch the %s image of '%s': %w", want, src.str, err))
+		}
+		digest, err := img.Digest()
+		if err != nil {
+			return err
+		}
+		mediaType, err := img.MediaType()
+		if err != nil {
+			return err
+		}
+		src.digest, src.target, src.mediaType, src.index = digest, digest, mediaType, false
+	} else {
+		want = *platform
+	}
+	src.coercedFrom = listDigest
+	out.printNotice(statusNote, fmt.Sprintf("Source '%s' is a manifest list (%s); only its %s image %s is promoted (--source-manifest-list-policy=%s)",
+		src.str, listDigest, want, src.digest, listPolicyCoerce))
+	return nil
+}
+
+func manifestListRefused(source string, mediaType types.MediaType) error {
+	return fmt.Errorf("Source image '%s' is a manifest list (%s), which --source-manifest-list-policy=%s refuses; promote one platform's image by digest, or use --source-manifest-list-policy=%s",
+		source, mediaType, listPolicyError, listPolicyCoerce)
+}
+
+// one destination tag and the image it gets
+type promotion struct {
+	src sourceImage
+	tag string
+}
+
+// With --platform all, the per-platform promotions: each platform's image in
+// the source index to every tag plus its --per-platform-suffix, e.g. prod to
+// prod-linux-arm64. Digest destinations are checks, so they get none.
+func platformPromotions(ctx context.Context, out *printer, src sourceImage, newTags []string) ([]promotion, error) {
+	if !src.index {
+		return nil, fmt.Errorf("--platform %s requires a multi-platform source, but '%s' is a single image (%s)", platformAll, src.str, src.mediaType)
+	}
+	var manifest *v1.IndexManifest
+	err := withRetry(ctx, out, "Listing source platforms", func() error {
+		idx, err := registryClient.Index(ctx, readRef(src.ref.Context().Digest(src.digest.String())))
+		if err != nil {
+			return err
+		}
+		manifest, err = idx.IndexManifest()
+		return err
+	})
+	if err != nil {
+		return nil, withExitCode(registryExitCode(err, exitSourceNotFound), fmt.Errorf("Failed to list the platforms of '%s': %w", src.str, err))
+	}
+
+	// Other non-image children carry no real platform; attestations are
+	// tagged after the image they describe, if asked to.
+	var children []platformChild
+	bySuffix := map[string]string{}
+	skipped := 0
+	for _, child := range manifest.Manifests {
+		c := platformChild{desc: child}
+		switch {
+		case isAttestation(child):
+			p := attestedPlatform(manifest, child)
+			if !includeAttestations || p == nil {
+				skipped++
+				continue
+			}
+			c.label = p.String() + " attestation"
+			c.suffix = expandPlatformSuffix(*p) + attestationSuffix
+		case child.Platform == nil || child.Platform.OS == "" || child.Platform.OS == "unknown":
+			continue
+		default:
+			c.label = child.Platform.String()
+			c.suffix = expandPlatformSuffix(*child.Platform)
+		}
+		if other, ok := bySuffix[c.suffix]; ok {
+			return nil, withExitCode(exitUsage, fmt.Errorf("%s and %s both map to the tag suffix '%s'; include {variant} in --per-platform-suffix", other, c.label, c.suffix))
+		}
+		bySuffix[c.suffix] = c.label
+		children = append(children, c)
+	}
+	if skipped > 0 && !includeAttestations {
+		out.printNotice(statusNote, fmt.Sprintf("'%s' also holds %d attestation %s, promoted with the index; use --include-attestations to tag them too",
+			src.str, skipped, plural(skipped, "manifest")))
+	}
+
+	var promotions []promotion
+	for _, child := range children {
+		childSrc, err := platformSource(ctx, out, src, child)
+		if err != nil {
+			return nil, err
+		}
+		for _, newTag := range newTags {
+			if _, isDigest, _ := parseDigestDestination(src.ref, newTag); isDigest {
+				continue
+			}
+			promotions = append(promotions, promotion{src: childSrc, tag: suffixedTag(src, newTag, child.suffix)})
+		}
+	}
+	return promotions, nil
+}
+
+// The image of one index child as a source of its own, pinned to its digest.
+func platformSource(ctx context.Context, out *printer, src sourceImage, child platformChild) (sourceImage, error) {
+	ref := src.ref.Context().Digest(child.desc.Digest.String())
+	var details imageDetails
+	err := withRetry(ctx, out, fmt.Sprintf("Fetching %s image", child.label), func() (err error) {
+		details, _, err = fetchImage(ctx, ref)
+		return err
+	})
+	if err != nil {
+		return sourceImage{}, withExitCode(registryExitCode(err, exitSourceNotFound), fmt.Errorf("Failed to fetch the %s image of '%s': %w", child.label, src.str, err))
+	}
+	childSrc := sourceImage{str: ref.String(), ref: ref, digest: child.desc.Digest, config: details.config, created: details.created, labels: details.labels,
+		mediaType: child.desc.MediaType, target: child.desc.Digest}
+	if size, err := fetchImageSize(ctx, ref); err == nil {
+		childSrc.size = &size
+	}
+	return childSrc, nil
+}
+
+// one index child to tag, e.g. linux/arm64 with suffix -linux-arm64
+type platformChild struct {
+	desc   v1.Descriptor
+	label  string
+	suffix string
+}
+
+// The destination with the suffix appended to its tag, e.g. reg/app:prod to
+// reg/app:prod-linux-amd64; a bare tag stays bare.
+func suffixedTag(src sourceImage, newTag, suffix string) string {
+	if !strings.ContainsAny(newTag, "/:@") {
+		return newTag + suffix
+	}
+	dest, err := parseDestination(src.ref, newTag)
+	if err != nil {
+		// reported when the tag is retagged
+		return newTag + suffix
+	}
+	return dest.Context().Tag(dest.TagStr() + suffix).String()
+}
+
+// --per-platform-suffix with {os}, {arch} and {variant} filled in
+func expandPlatformSuffix(p v1.Platform) string {
+	return strings.NewReplacer("{os}", p.OS, "{arch}", p.Architecture, "{variant}", p.Variant).Replace(platformSuffix)
+}
+
+// the --expected-os and --expected-arch platform, with * for either left out
+func expectedPlatform() string {
+	goos, arch := expectedOS, expectedArch
+	if goos == "" {
+		goos = "*"
+	}
+	if arch == "" {
+		arch = "*"
+	}
+	return goos + "/" + arch
+}
+
+func isExpectedPlatform(goos, arch string) bool {
+	return (expectedOS == "" || goos == expectedOS) && (expectedArch == "" || arch == expectedArch)
+}
+
+// Enforce --expected-os and --expected-arch. An image is checked by its
+// config; a manifest list by the platforms it lists, any or all of which
+// must match depending on --expected-platform-match. Attestations are no
+// platform's image, so they are left out.
+func checkExpectedPlatform(ctx context.Context, out *printer, src sourceImage) error {
+	if expectedOS == "" && expectedArch == "" {
+		return nil
+	}
+	if !src.index {
+		if src.os == "" || src.arch == "" {
+			return fmt.Errorf("Source image '%s' has no platform in its config, expected %s (--expected-os, --expected-arch)", src.str, expectedPlatform())
+		}
+		if !isExpectedPlatform(src.os, src.arch) {
+			return fmt.Errorf("Source image '%s' is %s/%s, expected %s (--expected-os, --expected-arch)", src.str, src.os, src.arch, expectedPlatform())
+		}
+		return nil
+	}
+
+	var manifest *v1.IndexManifest
+	idx, local := src.artifact.(v1.ImageIndex)
+	err := withRetry(ctx, out, "Listing source platforms", func() (err error) {
+		if !local {
+			if idx, err = registryClient.Index(ctx, readRef(src.ref.Context().Digest(src.digest.String()))); err != nil {
+				return err
+			}
+		}
+		manifest, err = idx.IndexManifest()
+		return err
+	})
+	if err != nil {
+		return withExitCode(registryExitCode(err, exitSourceNotFound), fmt.Errorf("Failed to list the platforms of '%s': %w", src.str, err))
+	}
+	var listed, matching []string
+	for _, m := range manifest.Manifests {
+		if m.Platform == nil || isAttestation(m) {
+			continue
+		}
+		listed = append(listed, m.Platform.String())
+		if isExpectedPlatform(m.Platform.OS, m.Platform.Architecture) {
+			matching = append(matching, m.Platform.String())
+		}
+	}
+	switch {
+	case len(matching) == 0:
+		return fmt.Errorf("Source image '%s' has no %s image; its platforms are %s (--expected-os, --expected-arch)", src.str, expectedPlatform(), strings.Join(listed, ", "))
+	case expectedMatch == platformMatchAll && len(matching) < len(listed):
+		return fmt.Errorf("Source image '%s' has platforms other than %s: %s (--expected-platform-match=%s)", src.str, expectedPlatform(), strings.Join(listed, ", "), platformMatchAll)
+	}
+	return nil
+}
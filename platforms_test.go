@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+func TestExpandPlatformSuffix(t *testing.T) {
+	arm := v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}
+	amd := v1.Platform{OS: "windows", Architecture: "amd64"}
+	tests := []struct {
+		suffix string
+		p      v1.Platform
+		want   string
+	}{
+		{"-{os}-{arch}", arm, "-linux-arm"},
+		{"-{os}-{arch}", amd, "-windows-amd64"},
+		{"-{arch}{variant}", arm, "-armv7"},
+		{"-{arch}{variant}", amd, "-amd64"},
+		{"_{os}_{os}", amd, "_windows_windows"},
+		{"-static", arm, "-static"},
+	}
+	t.Cleanup(func() { platformSuffix = "-{os}-{arch}" })
+	for _, tt := range tests {
+		platformSuffix = tt.suffix
+		if got := expandPlatformSuffix(tt.p); got != tt.want {
+			t.Errorf("%q for %s: %q, want %q", tt.suffix, tt.p.String(), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// --progress: periodically report the bytes written during a copy
+var showProgress bool
+
+const progressInterval = 5 * time.Second
+
+// reports the progress of one write on out's stderr every progressInterval. Byte
+// counts come from go-containerregistry's progress updates; when there are
+// none (e.g. a manifest-only write), a "still working" line is printed instead.
+type progressReporter struct {
+	out     *printer
+	what    string
+	updates chan v1.Update
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// Start reporting on a write. The returned reporter must be stopped once the
+// write returns.
+func startProgress(out *printer, what string) *progressReporter {
+	p := &progressReporter{out: out, what: what, updates: make(chan v1.Update, 1), done: make(chan struct{})}
+	p.wg.Add(1)
+	go p.run()
+	return p
+}
+
+func (p *progressReporter) run() {
+	defer p.wg.Done()
+	start := time.Now()
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+
+	updates := p.updates
+	var last v1.Update
+	// A batch or mirror line's are buffered with the rest of its output, so
+	// lines stay together.
+	for {
+		select {
+		case u, ok := <-updates:
+			if !ok {
+				updates = nil // the write has finished
+				continue
+			}
+			last = u
+		case <-ticker.C:
+			elapsed := time.Since(start).Round(time.Second)
+			if last.Total > 0 {
+				p.out.printNotice(statusProgress, fmt.Sprintf("%s: %s of %s (%d%%, %s elapsed)",
+					p.what, formatBytes(last.Complete), formatBytes(last.Total), last.Complete*100/last.Total, elapsed))
+			} else {
+				p.out.printNotice(statusProgress, fmt.Sprintf("%s: still working (%s elapsed)", p.what, elapsed))
+			}
+		case <-p.done:
+			return
+		}
+	}
+}
+
+func (p *progressReporter) stop() {
+	close(p.done)
+	p.wg.Wait()
+}
+
+// crane option sending byte-level updates to the reporter
+func (p *progressReporter) craneOption() crane.Option {
+	return func(o *crane.Options) {
+		o.Remote = append(o.Remote, remote.WithProgress(p.updates))
+	}
+}
+
+func (p *progressReporter) remoteOption() remote.Option {
+	return remote.WithProgress(p.updates)
+}
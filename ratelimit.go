@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// --max-rate: registry requests per second across all goroutines; 0 means unlimited
+var maxRate float64
+
+// --concurrency-per-registry: writes in flight at once to one destination
+// registry host, whatever --parallel allows; 0 means no per-host limit
+var registryConcurrency int
+
+// registry host -> semaphore channel for --concurrency-per-registry
+var registrySlots sync.Map
+
+// Wait for one of host's --concurrency-per-registry write slots. release
+// must be called once the write is done.
+func acquireRegistry(ctx context.Context, host string) (release func(), err error) {
+	if registryConcurrency <= 0 {
+		return func() {}, nil
+	}
+	v, _ := registrySlots.LoadOrStore(host, make(chan struct{}, registryConcurrency))
+	sem := v.(chan struct{})
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// token bucket holding up to one second's worth of requests
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	burst := max(rate, 1)
+	return &rateLimiter{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// Take a token, returning how long the caller must wait before sending.
+// Tokens may go negative, which queues concurrent callers behind each other.
+func (l *rateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// Empty the bucket so later requests are spaced out at the full rate.
+func (l *rateLimiter) drain() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens = min(l.tokens, 0)
+}
+
+// delays each request to respect --max-rate, and backs off further when the
+// registry answers 429 Too Many Requests
+type rateLimitedTransport struct {
+	inner   http.RoundTripper
+	limiter *rateLimiter
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if wait := t.limiter.reserve(); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+	resp, err := t.inner.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+		t.limiter.drain()
+	}
+	return resp, err
+}
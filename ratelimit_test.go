@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+	tests := []struct {
+		rate float64
+		want []time.Duration // the wait for each request in a burst
+	}{
+		// A second's worth of requests go at once, then they queue.
+		{2, []time.Duration{0, 0, 500 * time.Millisecond, time.Second}},
+		// Below one per second, the bucket still holds one request.
+		{0.5, []time.Duration{0, 2 * time.Second, 4 * time.Second}},
+	}
+	for _, tt := range tests {
+		l := newRateLimiter(tt.rate)
+		for i, want := range tt.want {
+			// Tokens refill between calls, so waits may come out a bit short.
+			if got := l.reserve(); got > want || got < want-50*time.Millisecond {
+				t.Errorf("rate %g: request %d waits %s, want %s", tt.rate, i+1, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// --copy-referrers: also copy the OCI referrers (SBOMs, attestations, ...) of the source
+var copyReferrers bool
+
+// Copy every artifact referring to the copied source digest (the manifest
+// list with --platform) into the destination repository, returning the
+// copied digests. Registries without the Referrers API are served by
+// remote.Referrers' fallback tag, and one with no referrers at all is a
+// no-op. Nothing needs copying within the source repository, so nil is
+// returned there.
+func copyReferrerArtifacts(ctx context.Context, out *printer, src sourceImage, dest name.Repository) ([]string, error) {
+	if dest == src.ref.Context() {
+		return nil, nil
+	}
+
+	subject := src.ref.Context().Digest(copiedDigest(src).String())
+	var manifest *v1.IndexManifest
+	err := withRetry(ctx, out, "Listing referrers", func() error {
+		idx, err := registryClient.Referrers(ctx, subject)
+		if err != nil {
+			return err
+		}
+		manifest, err = idx.IndexManifest()
+		return err
+	})
+	if isNotFound(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list referrers of '%s': %w", subject, err)
+	}
+
+	copied := []string{}
+	for _, desc := range manifest.Manifests {
+		from := src.ref.Context().Digest(desc.Digest.String()).String()
+		to := dest.Digest(desc.Digest.String()).String()
+		err := withRetry(ctx, out, fmt.Sprintf("Copying referrer %s", formatDigest(desc.Digest)), func() error {
+			return registryClient.Copy(ctx, from, to, writeOptions{})
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Failed to copy referrer '%s' to '%s': %w", from, to, err)
+		}
+		copied = append(copied, desc.Digest.String())
+	}
+	return copied, nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func TestPlatformCopiesTheListsReferrers(t *testing.T) {
+	host := newTestRegistry(t)
+	list := pushIndex(t, host+"/dev/app:multi")
+	img, err := random.Image(64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sbom := mutate.Subject(mutate.MediaType(img, types.OCIManifestSchema1), v1.Descriptor{MediaType: types.OCIImageIndex, Digest: list}).(v1.Image)
+	want := digestOf(t, sbom)
+	ref, err := name.NewDigest(host + "/dev/app@" + want.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, sbom); err != nil {
+		t.Fatal(err)
+	}
+	platform = &v1.Platform{OS: "linux", Architecture: "arm64"}
+	copyReferrers = true
+	t.Cleanup(func() { platform, copyReferrers = nil, false })
+
+	res := retagForTest(t, host+"/dev/app:multi", host+"/prod/app:release")
+	if len(res.CopiedReferrers) != 1 || res.CopiedReferrers[0] != want.String() {
+		t.Errorf("copied referrers %q, want the list's %s", res.CopiedReferrers, want)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// The registry operations commands are built from. registryClient is the
+// go-containerregistry implementation; a test can swap in a fake to drive
+// the idempotency and error paths without a registry. Every call uses the
+// shared auth, transport and --platform options.
+type registry interface {
+	// the manifest ref points to (the --platform image of a manifest list)
+	Get(ctx context.Context, ref name.Reference) (*remote.Descriptor, error)
+	Head(ctx context.Context, ref name.Reference) (*v1.Descriptor, error)
+	Image(ctx context.Context, ref name.Reference) (v1.Image, error)
+	Index(ctx context.Context, ref name.Reference) (v1.ImageIndex, error)
+	Referrers(ctx context.Context, subject name.Digest) (v1.ImageIndex, error)
+	List(ctx context.Context, repo name.Repository) ([]string, error)
+	Delete(ctx context.Context, ref name.Reference) error
+
+	// point tag, in src's repository, at src by re-pushing only its manifest
+	Tag(ctx context.Context, src, tag string, w writeOptions) error
+	// copy src, blobs included, to dst in another repository
+	Copy(ctx context.Context, src, dst string, w writeOptions) error
+	// push an image or index and any blobs missing from ref's repository
+	Write(ctx context.Context, ref name.Reference, t remote.Taggable, w writeOptions) error
+}
+
+// per-write overrides of the shared options; the zero value changes nothing
+type writeOptions struct {
+	transport http.RoundTripper // e.g. wrapped to count blob transfers
+	progress  *progressReporter
+}
+
+var registryClient registry = remoteRegistry{}
+
+// registry backed by go-containerregistry's remote and crane packages
+type remoteRegistry struct{}
+
+func (remoteRegistry) Get(ctx context.Context, ref name.Reference) (*remote.Descriptor, error) {
+	return remote.Get(ref, remoteOptions(ctx)...)
+}
+
+func (remoteRegistry) Head(ctx context.Context, ref name.Reference) (*v1.Descriptor, error) {
+	return remote.Head(ref, remoteOptions(ctx)...)
+}
+
+func (remoteRegistry) Image(ctx context.Context, ref name.Reference) (v1.Image, error) {
+	return remote.Image(ref, remoteOptions(ctx)...)
+}
+
+func (remoteRegistry) Index(ctx context.Context, ref name.Reference) (v1.ImageIndex, error) {
+	return remote.Index(ref, remoteOptions(ctx)...)
+}
+
+func (remoteRegistry) Referrers(ctx context.Context, subject name.Digest) (v1.ImageIndex, error) {
+	return remote.Referrers(subject, remoteOptions(ctx)...)
+}
+
+func (remoteRegistry) List(ctx context.Context, repo name.Repository) ([]string, error) {
+	return remote.List(repo, remoteOptions(ctx)...)
+}
+
+func (remoteRegistry) Delete(ctx context.Context, ref name.Reference) error {
+	return remote.Delete(ref, remoteOptions(ctx)...)
+}
+
+func (remoteRegistry) Tag(ctx context.Context, src, tag string, w writeOptions) error {
+	return crane.Tag(src, tag, w.craneOptions(ctx)...)
+}
+
+func (remoteRegistry) Copy(ctx context.Context, src, dst string, w writeOptions) error {
+	return crane.Copy(src, dst, w.craneOptions(ctx)...)
+}
+
+func (remoteRegistry) Write(ctx context.Context, ref name.Reference, t remote.Taggable, w writeOptions) error {
+	opts := w.remoteOptions(ctx)
+	if idx, ok := t.(v1.ImageIndex); ok {
+		return remote.WriteIndex(ref, idx, opts...)
+	}
+	return remote.Write(ref, t.(v1.Image), opts...)
+}
+
+func (w writeOptions) craneOptions(ctx context.Context) []crane.Option {
+	opts := craneOptions(ctx)
+	if w.transport != nil {
+		opts = append(opts, crane.WithTransport(w.transport))
+	}
+	if w.progress != nil {
+		opts = append(opts, w.progress.craneOption())
+	}
+	return opts
+}
+
+func (w writeOptions) remoteOptions(ctx context.Context) []remote.Option {
+	opts := remoteOptions(ctx)
+	if w.transport != nil {
+		opts = append(opts, remote.WithTransport(w.transport))
+	}
+	if w.progress != nil {
+		opts = append(opts, w.progress.remoteOption())
+	}
+	return opts
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+var (
+	retries    int
+	retryDelay time.Duration
+	// --retry-budget: cap on the time one operation spends retrying; 0 means none
+	retryBudget time.Duration
+
+	// --retry-on-429: retry rate-limited requests, honouring Retry-After
+	retryOn429 bool
+	// --max-retry-after: cap on a server-provided Retry-After delay
+	maxRetryAfter time.Duration
+
+	// --wait-for-source: how long to keep polling for a source that doesn't
+	// exist yet; 0 means fail at once
+	sourceWait time.Duration
+	// --wait-interval: delay between those polls
+	sourceWaitInterval time.Duration
+)
+
+// Run fn, retrying transient failures up to --retries times with jittered
+// exponential backoff starting at --retry-delay. Each retry is announced on
+// stderr. A retry that would end past --retry-budget isn't started; the error
+// then says how many attempts were made.
+func withRetry(ctx context.Context, out *printer, what string, fn func() error) error {
+	start := time.Now()
+	delay := retryDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt > retries || !isRetryable(err) {
+			return err
+		}
+		wait := jitter(delay)
+		if retryBudget > 0 && time.Since(start)+wait > retryBudget {
+			return fmt.Errorf("%s failed after %d %s within the --retry-budget of %s: %w", what, attempt, plural(attempt, "attempt"), retryBudget, err)
+		}
+		out.printNotice(statusRetry, fmt.Sprintf("%s failed (attempt %d/%d), retrying in %s: %v", what, attempt, retries+1, wait, err))
+		runMetrics.retries.Add(1)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		delay *= 2
+	}
+}
+
+// Run fetch, and while it reports the source as not found, run it again every
+// --wait-interval until --wait-for-source has passed, for a promotion that
+// starts before the build's push has finished. Each poll is announced on
+// stderr; other errors end the wait at once.
+func waitForSource(ctx context.Context, out *printer, source string, fetch func() error) error {
+	start := time.Now()
+	for {
+		err := fetch()
+		if err == nil || sourceWait <= 0 || !isNotFound(err) {
+			return err
+		}
+		if time.Since(start)+sourceWaitInterval > sourceWait {
+			return fmt.Errorf("still missing after waiting %s (--wait-for-source): %w", sourceWait.Round(time.Second), err)
+		}
+		out.printNotice(statusWaiting, fmt.Sprintf("Source image '%s' not found yet; checking again in %s", source, sourceWaitInterval))
+		select {
+		case <-time.After(sourceWaitInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Randomize a backoff delay to between half and all of d, so parallel
+// promotions failing against the same registry don't retry in lockstep.
+func jitter(d time.Duration) time.Duration {
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return (half + rand.N(d-half+1)).Round(time.Millisecond)
+}
+
+// Only timeouts, dropped or refused connections and 5xx responses are worth
+// retrying; auth, not-found and TLS errors will not fix themselves. Every
+// error from the HTTP client is a net.Error, so that alone says nothing.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	// --manifest-only refused the upload; trying again would be refused too.
+	if errors.Is(err, errBlobUpload) {
+		return false
+	}
+	var hidden transportError
+	if errors.As(err, &hidden) {
+		err = hidden.err
+	}
+
+	var terr *transport.Error
+	if errors.As(err, &terr) {
+		return terr.StatusCode >= 500
+	}
+
+	var nerr net.Error
+	if errors.As(err, &nerr) && nerr.Timeout() {
+		return true
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// Whether a registry error means the manifest or repository does not exist.
+func isNotFound(err error) bool {
+	var terr *transport.Error
+	if !errors.As(err, &terr) {
+		return false
+	}
+	if terr.StatusCode == http.StatusNotFound {
+		return true
+	}
+	for _, d := range terr.Errors {
+		if d.Code == transport.ManifestUnknownErrorCode || d.Code == transport.NameUnknownErrorCode {
+			return true
+		}
+	}
+	return false
+}
+
+// retries requests answered with 429 Too Many Requests, up to --retries
+// times. The server's Retry-After delay (capped at --max-retry-after) is
+// preferred over the usual exponential backoff. Requests whose body can't be
+// replayed are returned as-is. Retries are announced on the printer the
+// request's context carries.
+type retry429Transport struct {
+	inner http.RoundTripper
+}
+
+func (t *retry429Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	delay := retryDelay
+	for attempt := 1; ; attempt++ {
+		resp, err := t.inner.RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests || attempt > retries {
+			return resp, err
+		}
+		if req.Body != nil && req.GetBody == nil {
+			return resp, nil
+		}
+
+		wait := jitter(delay)
+		if after, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			wait = min(after, maxRetryAfter)
+		}
+		if retryBudget > 0 && time.Since(start)+wait > retryBudget {
+			printerFrom(req.Context()).printNotice(statusRetry, fmt.Sprintf("%s %s rate limited (attempt %d/%d); giving up, the --retry-budget of %s is exhausted", req.Method, req.URL.Redacted(), attempt, retries+1, retryBudget))
+			return resp, nil
+		}
+		resp.Body.Close()
+		printerFrom(req.Context()).printNotice(statusRetry, fmt.Sprintf("%s %s rate limited (attempt %d/%d), retrying in %s", req.Method, req.URL.Redacted(), attempt, retries+1, wait))
+
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+		delay *= 2
+	}
+}
+
+// go-containerregistry wraps every transport in one that quietly retries
+// network errors such as a reset connection, and that can't be switched off.
+// If it can't recognize them, each such failure reaches withRetry after one
+// attempt, so isRetryable looks through the wrapper itself. Errors of a
+// request whose context is done pass unwrapped, for the deadline checks.
+type noLibraryRetryTransport struct {
+	inner http.RoundTripper
+}
+
+func (t *noLibraryRetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.inner.RoundTrip(req)
+	if err != nil && req.Context().Err() == nil {
+		return resp, transportError{err}
+	}
+	return resp, err
+}
+
+// a network error hidden from go-containerregistry's retries; it deliberately
+// has no Unwrap method
+type transportError struct{ err error }
+
+func (e transportError) Error() string { return e.err.Error() }
+
+// Retry-After is either a number of seconds or an HTTP date.
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+func TestJitter(t *testing.T) {
+	tests := []struct {
+		d        time.Duration
+		min, max time.Duration
+	}{
+		{0, 0, 0},
+		{time.Nanosecond, time.Nanosecond, time.Nanosecond},
+		{time.Second, 500 * time.Millisecond, time.Second},
+		{10 * time.Second, 5 * time.Second, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		for range 100 {
+			if got := jitter(tt.d); got < tt.min || got > tt.max {
+				t.Errorf("jitter(%s) = %s, want between %s and %s", tt.d, got, tt.min, tt.max)
+				break
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value    string
+		min, max time.Duration
+		ok       bool
+	}{
+		{"", 0, 0, false},
+		{"0", 0, 0, true},
+		{"120", 2 * time.Minute, 2 * time.Minute, true},
+		{"-1", 0, 0, false},
+		{"soon", 0, 0, false},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, 0, true},
+		{time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), 58 * time.Minute, time.Hour, true},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value)
+		if ok != tt.ok || got < tt.min || got > tt.max {
+			t.Errorf("parseRetryAfter(%q) = %s, %v; want %s to %s, %v", tt.value, got, ok, tt.min, tt.max, tt.ok)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	urlErr := func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://registry.example.com/v2/", Err: err}
+	}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"timeout", urlErr(os.ErrDeadlineExceeded), true},
+		{"connection reset", urlErr(syscall.ECONNRESET), true},
+		{"connection reset, hidden from go-containerregistry", urlErr(transportError{syscall.ECONNRESET}), true},
+		{"connection refused", urlErr(syscall.ECONNREFUSED), true},
+		{"EOF", urlErr(io.EOF), true},
+		{"server error", &transport.Error{StatusCode: http.StatusBadGateway}, true},
+		{"untrusted certificate", urlErr(x509.UnknownAuthorityError{}), false},
+		{"refused upload", urlErr(errBlobUpload), false},
+		{"unauthorized", &transport.Error{StatusCode: http.StatusUnauthorized}, false},
+		{"canceled", urlErr(errors.Join(syscall.ECONNRESET, context.Canceled)), false},
+	}
+	for _, tt := range tests {
+		if got := isRetryable(tt.err); got != tt.want {
+			t.Errorf("isRetryable(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRetriesZeroMakesOneAttempt(t *testing.T) {
+	tests := []struct {
+		name  string
+		serve func(w http.ResponseWriter)
+	}{
+		{"server error", func(w http.ResponseWriter) { w.WriteHeader(http.StatusServiceUnavailable) }},
+		{"connection reset", func(w http.ResponseWriter) {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				return
+			}
+			// Closing with no linger sends a reset instead of a FIN.
+			conn.(*net.TCPConn).SetLinger(0)
+			conn.Close()
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fetches atomic.Int32
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/v2/" {
+					// net/http itself resends a request once when a reused
+					// connection resets, so make every request a new one.
+					w.Header().Set("Connection", "close")
+					return
+				}
+				fetches.Add(1)
+				tt.serve(w)
+			}))
+			t.Cleanup(s.Close)
+			retries, registryTransport = 0, newTransport()
+			t.Cleanup(func() { retries, registryTransport = 3, remote.DefaultTransport })
+
+			_, err := resolveSource(context.Background(), newBufferedPrinter(), strings.TrimPrefix(s.URL, "http://")+"/app:build-1")
+			if err == nil {
+				t.Fatal("resolving the source succeeded")
+			}
+			if n := fetches.Load(); n != 1 {
+				t.Errorf("%d manifest requests, want 1: %v", n, err)
+			}
+		})
+	}
+}
+
+func TestRateLimitRetryGoesToTheLinesPrinter(t *testing.T) {
+	inner := newTestRegistry(t)
+	pushImage(t, inner+"/app:build-1")
+	// The first manifest request is rate limited; the rest reach the registry.
+	proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: inner})
+	var limited atomic.Bool
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/manifests/") && !limited.Swap(true) {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+	retryOn429 = true
+	registryTransport = newTransport()
+	std := stdPrinter
+	stdPrinter = newBufferedPrinter()
+	t.Cleanup(func() { retryOn429, registryTransport, stdPrinter = false, remote.DefaultTransport, std })
+
+	out := newBufferedPrinter()
+	ctx := withPrinter(context.Background(), out)
+	if _, err := resolveSource(ctx, out, strings.TrimPrefix(s.URL, "http://")+"/app:build-1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.stderr.(*lockedBuffer).String(); !strings.Contains(got, "[RETRY]") {
+		t.Errorf("line's stderr %q, want the rate-limit retry", got)
+	}
+	if got := stdPrinter.stderr.(*lockedBuffer).String(); got != "" {
+		t.Errorf("the retry also went to the shared printer: %q", got)
+	}
+}
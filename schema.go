@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// The JSON objects --output=json writes, by schema definition name. The
+// schemas are generated from these types, so they can't drift from the output.
+var outputSchemas = []struct {
+	name        string
+	value       any
+	description string
+}{
+	{"result", retagResult{}, "One destination tag of a retag or mirror, on stdout"},
+	{"error", errorResult{}, "A failure, on stderr"},
+	{"notice", noticeResult{}, "A warning, note, retry or other progress message, on stderr"},
+	{"copy", copyResult{}, "The outcome of the copy subcommand"},
+	{"index", indexResult{}, "The outcome of the index subcommand"},
+	{"digest", digestResult{}, "The outcome of the digest subcommand"},
+	{"delete", deleteResult{}, "The outcome of the delete subcommand"},
+	{"ping", pingResult{}, "The outcome of the ping subcommand"},
+	{"list", tagEntry{}, "One tag printed by the list subcommand"},
+	{"inspect", imageInspect{}, "The document printed by the inspect subcommand (always JSON)"},
+}
+
+func newSchemaCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema of the --output=json objects",
+		Long: `Print a JSON Schema (draft 2020-12) describing every object docker-retag
+writes with --output=json: retag results, errors and notices, and the
+output of each subcommand, under "$defs". Fields are required unless
+they are left out when empty, and may be null where the value can be
+unknown. Tooling can check its expectations against it, or generate typed
+clients.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			out, err := json.MarshalIndent(outputSchema(), "", "  ")
+			if err != nil {
+				exitf(exitFailure, "Failed to encode the schema: %v", err)
+			}
+			fmt.Println(string(out))
+		},
+	}
+}
+
+func outputSchema() map[string]any {
+	defs := map[string]any{}
+	var refs []any
+	for _, s := range outputSchemas {
+		schema := typeSchema(reflect.TypeOf(s.value))
+		schema["description"] = s.description
+		defs[s.name] = schema
+		refs = append(refs, map[string]any{"$ref": "#/$defs/" + s.name})
+	}
+	return map[string]any{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title":   "docker-retag " + version + " JSON output",
+		"oneOf":   refs,
+		"$defs":   defs,
+	}
+}
+
+// Schema for the JSON encoding of t. Pointers, slices and maps may be null,
+// since encoding/json writes nil ones that way; fields without omitempty
+// are required.
+func typeSchema(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return nullable(typeSchema(t.Elem()))
+	case reflect.Slice:
+		return nullable(map[string]any{"type": "array", "items": typeSchema(t.Elem())})
+	case reflect.Map:
+		return nullable(map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())})
+	case reflect.Struct:
+		props := map[string]any{}
+		required := []string{}
+		for i := range t.NumField() {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			if !f.IsExported() || tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = typeSchema(f.Type)
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		return map[string]any{"type": "object", "properties": props, "required": required}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float64:
+		return map[string]any{"type": "number"}
+	}
+	return map[string]any{"type": "string"}
+}
+
+func nullable(schema map[string]any) map[string]any {
+	return map[string]any{"anyOf": []any{schema, map[string]any{"type": "null"}}}
+}
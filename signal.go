@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+)
+
+// set once SIGINT or SIGTERM has cancelled the operation context
+var interrupted atomic.Bool
+
+// Cancel ctx on the first SIGINT or SIGTERM, so in-flight registry calls
+// (and the remaining blob uploads of a copy) abort and the command exits
+// with exitInterrupted. A second signal exits immediately.
+func cancelOnSignal(cancel context.CancelFunc) {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		interrupted.Store(true)
+		stdPrinter.printNotice(statusInterrupted, "Received "+sig.String()+", cancelling in-flight registry operations (signal again to exit immediately)")
+		cancel()
+		<-signals
+		os.Exit(exitInterrupted)
+	}()
+}
+
+// Exit with code, or exitInterrupted if a signal cut the operation short,
+// writing the --metrics-file first.
+func exit(code int) {
+	code = interruptedCode(code)
+	writeMetrics(code)
+	os.Exit(code)
+}
+
+// code, unless the failure it reports came from a signal's cancellation
+func interruptedCode(code int) int {
+	if code != exitOK && interrupted.Load() {
+		return exitInterrupted
+	}
+	return code
+}
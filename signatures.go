@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// --copy-signatures: also copy cosign's signature, attestation and SBOM tags
+var copySignatures bool
+
+// suffixes of the tags cosign attaches to an image digest
+var cosignSuffixes = []string{".sig", ".att", ".sbom"}
+
+// cosign's tag for an artifact attached to digest, e.g. sha256-<hex>.sig
+func cosignTag(digest v1.Hash, suffix string) string {
+	return digest.Algorithm + "-" + digest.Hex + suffix
+}
+
+// Copy the cosign tags attached to the copied source digest (the manifest
+// list with --platform) into the destination repository, returning the
+// suffixes that were copied. Tags that don't exist (the image isn't signed or
+// has no SBOM) are skipped. Nothing needs copying within the source
+// repository, where the tags already live, so nil is returned there.
+func copyCosignTags(ctx context.Context, out *printer, src sourceImage, dest name.Repository) ([]string, error) {
+	if dest == src.ref.Context() {
+		return nil, nil
+	}
+	copied := []string{}
+	for _, suffix := range cosignSuffixes {
+		tag := cosignTag(copiedDigest(src), suffix)
+		from := src.ref.Context().Tag(tag).String()
+		to := dest.Tag(tag).String()
+		err := withRetry(ctx, out, fmt.Sprintf("Copying '%s'", tag), func() error {
+			return registryClient.Copy(ctx, from, to, writeOptions{})
+		})
+		if isNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to copy '%s' to '%s': %w", from, to, err)
+		}
+		copied = append(copied, suffix)
+	}
+	return copied, nil
+}
+
+// human-readable list of copied cosign tags, e.g. ".sig, .att"
+func formatSignatures(copied []string) string {
+	if len(copied) == 0 {
+		return "none found"
+	}
+	return strings.Join(copied, ", ")
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+func TestPlatformCopiesTheListsSignature(t *testing.T) {
+	host := newTestRegistry(t)
+	list := pushIndex(t, host+"/dev/app:multi")
+	pushImage(t, host+"/dev/app:"+cosignTag(list, ".sig"))
+	platform = &v1.Platform{OS: "linux", Architecture: "arm64"}
+	copySignatures = true
+	t.Cleanup(func() { platform, copySignatures = nil, false })
+
+	res := retagForTest(t, host+"/dev/app:multi", host+"/prod/app:release")
+	if len(res.CopiedSignatures) != 1 || res.CopiedSignatures[0] != ".sig" {
+		t.Errorf("copied signatures %q, want the list's .sig", res.CopiedSignatures)
+	}
+	headDigest(t, host+"/prod/app:"+cosignTag(list, ".sig"))
+}
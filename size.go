@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// layer count and compressed size of an image, summed across platforms for
+// a manifest list; attestation manifests are counted but not summed
+type imageSize struct {
+	platforms    int
+	attestations int
+	layers       int
+	bytes        int64
+}
+
+// Size of a remote image, honouring --platform for manifest lists.
+func fetchImageSize(ctx context.Context, ref name.Reference) (imageSize, error) {
+	desc, err := registryClient.Get(ctx, ref)
+	if err != nil {
+		return imageSize{}, err
+	}
+	if desc.MediaType.IsIndex() && platform == nil {
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return imageSize{}, err
+		}
+		return sizeOf(idx)
+	}
+	img, err := desc.Image()
+	if err != nil {
+		return imageSize{}, err
+	}
+	return sizeOf(img)
+}
+
+// Sizes come from the manifests' layer descriptors, so no blobs are downloaded.
+func sizeOf(t any) (imageSize, error) {
+	switch t := t.(type) {
+	case v1.ImageIndex:
+		manifest, err := t.IndexManifest()
+		if err != nil {
+			return imageSize{}, err
+		}
+		var total imageSize
+		for _, desc := range manifest.Manifests {
+			if isAttestation(desc) {
+				total.attestations++
+				continue
+			}
+			if !desc.MediaType.IsImage() {
+				continue
+			}
+			img, err := t.Image(desc.Digest)
+			if err != nil {
+				return imageSize{}, err
+			}
+			s, err := sizeOf(img)
+			if err != nil {
+				return imageSize{}, err
+			}
+			total.platforms++
+			total.layers += s.layers
+			total.bytes += s.bytes
+		}
+		return total, nil
+	case v1.Image:
+		manifest, err := t.Manifest()
+		if err != nil {
+			return imageSize{}, err
+		}
+		s := imageSize{platforms: 1, layers: len(manifest.Layers)}
+		for _, layer := range manifest.Layers {
+			s.bytes += layer.Size
+		}
+		return s, nil
+	}
+	return imageSize{}, fmt.Errorf("unsupported artifact type %T", t)
+}
+
+// e.g. "3 layers, 245.1 MB" or "2 platforms, 6 layers, 490.2 MB, 2 attestations"
+func (s imageSize) String() string {
+	str := fmt.Sprintf("%d %s, %s", s.layers, plural(s.layers, "layer"), formatBytes(s.bytes))
+	if s.platforms > 1 {
+		str = fmt.Sprintf("%d platforms, %s", s.platforms, str)
+	}
+	if s.attestations > 0 {
+		str += fmt.Sprintf(", %d %s", s.attestations, plural(s.attestations, "attestation"))
+	}
+	return str
+}
+
+// decimal (SI) units, as reported by docker
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// --timeout-per-layer: cancel a blob-copying write once no bytes have moved
+// for this long; 0 means no stall detection
+var layerTimeout time.Duration
+
+var errStalled = errors.New("no data transferred within --timeout-per-layer")
+
+// Cancels its context once the transport it wraps has been idle for too long.
+// Every request and every chunk of a request or response body pushes the
+// deadline back, so a slow upload that keeps moving is never cut off.
+type stallWatch struct {
+	mu     sync.Mutex
+	idle   time.Duration
+	timer  *time.Timer
+	cancel context.CancelCauseFunc
+}
+
+// A context for one write that --timeout-per-layer governs instead of
+// --timeout: the parent's deadline doesn't apply, but cancelling the
+// parent still does. stop must be called once the write is done.
+func watchStalls(parent context.Context, idle time.Duration) (context.Context, *stallWatch) {
+	ctx, cancel := context.WithCancelCause(context.WithoutCancel(parent))
+	context.AfterFunc(parent, func() {
+		if errors.Is(parent.Err(), context.Canceled) {
+			cancel(context.Cause(parent))
+		}
+	})
+	w := &stallWatch{idle: idle, cancel: cancel}
+	w.timer = time.AfterFunc(idle, func() { cancel(errStalled) })
+	return ctx, w
+}
+
+func (w *stallWatch) touch() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.timer.Reset(w.idle)
+}
+
+func (w *stallWatch) stop() {
+	w.timer.Stop()
+	w.cancel(nil)
+}
+
+// whether ctx, from watchStalls, was cancelled because nothing moved
+func stalled(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errStalled)
+}
+
+// reports every request and body chunk through it to the watch
+type stallTransport struct {
+	inner http.RoundTripper
+	watch *stallWatch
+}
+
+func (t *stallTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.watch.touch()
+	if req.Body != nil && req.Body != http.NoBody {
+		req = req.Clone(req.Context())
+		req.Body = &activityReader{ReadCloser: req.Body, w: t.watch}
+	}
+	resp, err := t.inner.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	t.watch.touch()
+	resp.Body = &activityReader{ReadCloser: resp.Body, w: t.watch}
+	return resp, nil
+}
+
+// counts any bytes read as activity
+type activityReader struct {
+	io.ReadCloser
+	w *stallWatch
+}
+
+func (r *activityReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.w.touch()
+	}
+	return n, err
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// --tag-template: render each destination argument as a Go template
+var tagTemplate bool
+
+// what a --tag-template destination can refer to
+type tagData struct {
+	// the digest the destination will point to, e.g. sha256:...
+	Digest string
+	// its first 12 hex characters
+	ShortDigest string
+	// when the source image was created; zero if unknown
+	Created time.Time
+	// Created as YYYYMMDD in UTC; empty if unknown
+	Date string
+	// the source's tag; empty for a digest or local source
+	Tag    string
+	Labels map[string]string
+	Env    map[string]string
+}
+
+// Render every destination as a template against src. An unknown field or
+// environment variable is an error rather than an empty string, and each
+// result must be a valid tag or reference.
+func renderTags(src sourceImage, newTags []string) ([]string, error) {
+	data := tagData{Digest: src.target.String(), Created: src.created, Labels: src.labels, Env: map[string]string{}}
+	data.ShortDigest = src.target.Hex
+	if len(data.ShortDigest) > 12 {
+		data.ShortDigest = data.ShortDigest[:12]
+	}
+	if !src.created.IsZero() {
+		data.Date = src.created.UTC().Format("20060102")
+	}
+	if src.ref != nil && !strings.Contains(src.ref.String(), "@") {
+		data.Tag = src.ref.Identifier()
+	}
+	for _, kv := range os.Environ() {
+		k, v, _ := strings.Cut(kv, "=")
+		data.Env[k] = v
+	}
+
+	rendered := make([]string, 0, len(newTags))
+	for _, newTag := range newTags {
+		tmpl, err := template.New("tag").Option("missingkey=error").Parse(newTag)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid --tag-template destination '%s': %v", newTag, err)
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, data); err != nil {
+			return nil, fmt.Errorf("Failed to render destination '%s': %v", newTag, err)
+		}
+		tag := b.String()
+		if _, isDigest, _ := parseDigestDestination(src.ref, tag); !isDigest {
+			if _, err := parseDestination(src.ref, tag); err != nil {
+				return nil, fmt.Errorf("Destination '%s' rendered to '%s', which is not a valid tag: %v", newTag, tag, err)
+			}
+		}
+		rendered = append(rendered, tag)
+	}
+	return rendered, nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+func TestRenderTags(t *testing.T) {
+	t.Setenv("DOCKER_RETAG_TEST_BUILD", "42")
+	digest := fakeDigest("image")
+	src := sourceImage{
+		ref:     name.MustParseReference("registry.example/app:build-7"),
+		target:  digest,
+		created: time.Date(2024, 6, 1, 23, 30, 0, 0, time.FixedZone("", -2*60*60)),
+		labels:  map[string]string{"version": "1.4.2"},
+	}
+	tests := []struct {
+		tmpl, want string // want is empty for an error
+	}{
+		{"{{.Tag}}-prod", "build-7-prod"},
+		{"{{.Date}}-{{.ShortDigest}}", "20240602-" + digest.Hex[:12]},
+		{"v{{.Labels.version}}", "v1.4.2"},
+		{"build-{{.Env.DOCKER_RETAG_TEST_BUILD}}", "build-42"},
+		{"other.example/app:{{.Tag}}", "other.example/app:build-7"},
+		{"{{.Digest}}", digest.String()},
+		{"plain", "plain"},
+		{"{{.Labels.missing}}", ""},
+		{"{{.Env.DOCKER_RETAG_TEST_UNSET}}", ""},
+		{"{{.Unknown}}", ""},
+		{"{{.Tag", ""},
+		{"{{.Labels.version}} release", ""},
+	}
+	for _, tt := range tests {
+		got, err := renderTags(src, []string{tt.tmpl})
+		switch {
+		case tt.want == "":
+			if err == nil {
+				t.Errorf("%q rendered to %q, want an error", tt.tmpl, got[0])
+			}
+		case err != nil:
+			t.Errorf("%q: %v", tt.tmpl, err)
+		case got[0] != tt.want:
+			t.Errorf("%q rendered to %q, want %q", tt.tmpl, got[0], tt.want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/logs"
+)
+
+// --verbose logs every registry round-trip to stderr
+var verbose bool
+
+// Route go-containerregistry's internal loggers to stderr so its debug and
+// warning messages appear alongside our own round-trip log.
+func configureLogging() {
+	if !verbose {
+		return
+	}
+	logs.Debug = log.New(os.Stderr, "[DEBUG] ", log.LstdFlags)
+	logs.Warn = log.New(os.Stderr, "[WARN] ", log.LstdFlags)
+}
+
+// logs the method, URL, status and duration of each request via logs.Debug
+type loggingTransport struct {
+	inner http.RoundTripper
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.inner.RoundTrip(req)
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		logs.Debug.Printf("%s %s: %v (%s)", req.Method, req.URL.Redacted(), err, elapsed)
+		return nil, err
+	}
+	logs.Debug.Printf("%s %s -> %s (%s)", req.Method, req.URL.Redacted(), resp.Status, elapsed)
+	return resp, nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/spf13/cobra"
+)
+
+const ggcrModule = "github.com/google/go-containerregistry"
+
+// one-line build description used by --version and the version subcommand
+func versionString() string {
+	return fmt.Sprintf("%s (commit: %s, built: %s, go-containerregistry: %s, %s)",
+		version, commit, buildTime, dependencyVersion(ggcrModule), runtime.Version())
+}
+
+// version of a module dependency as recorded in the binary, or "unknown"
+func dependencyVersion(module string) string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == module {
+			if dep.Replace != nil {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return "unknown"
+}
+
+func newVersionCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "version",
+		Short: "Print the build version, commit, build time and go-containerregistry version",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Printf("docker-retag %s\n", versionString())
+		},
+	}
+}